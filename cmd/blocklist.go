@@ -0,0 +1,44 @@
+// cmd/blocklist.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var blocklistCmd = &cobra.Command{
+	Use:   "blocklist",
+	Short: "Show releases that stalled and are skipped when looking for alternatives",
+	Run: func(cmd *cobra.Command, args []string) {
+		list := shared.LoadBlocklist()
+		if len(list) == 0 {
+			fmt.Println("📭 No blocked releases.")
+			return
+		}
+
+		fmt.Println("🚫 Blocked releases:")
+		for _, b := range list {
+			fmt.Printf("   - %s | %s | %s\n", ui.StyleFactory(b.Title, ui.Style.LBlue), b.Reason, b.Added.Format("2006-01-02 15:04"))
+		}
+	},
+}
+
+var blocklistClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Unblock all releases",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := shared.ClearBlocklist(); err != nil {
+			fmt.Printf("❌ Could not clear blocklist: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Cleared blocklist.")
+	},
+}
+
+func init() {
+	blocklistCmd.AddCommand(blocklistClearCmd)
+	rootCmd.AddCommand(blocklistCmd)
+}
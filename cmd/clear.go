@@ -5,10 +5,13 @@ package cmd
 import (
 	"fmt"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
 
 	"github.com/spf13/cobra"
 )
 
+var clearIncomplete bool
+
 var clearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear all temporary files, in case something stuck",
@@ -16,10 +19,19 @@ var clearCmd = &cobra.Command{
 
 		shared.ClearActiveDownloads()
 
+		if clearIncomplete {
+			if err := torrent.ClearIncomplete(shared.LoadConfig()); err != nil {
+				fmt.Printf("❌ Could not clear partial downloads: %v\n", err)
+				return
+			}
+			fmt.Println("🗑️  Removed partial downloads.")
+		}
+
 		fmt.Println("✅ Cleared temporary files.")
 	},
 }
 
 func init() {
+	clearCmd.Flags().BoolVar(&clearIncomplete, "incomplete", false, "Also remove partial downloads, they can't be resumed afterwards")
 	rootCmd.AddCommand(clearCmd)
 }
@@ -0,0 +1,147 @@
+// cmd/clients.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/downloader"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientType     string
+	clientURL      string
+	clientUsername string
+	clientPassword string
+	clientPriority int
+	clientRes      []string
+	clientMinSize  int64
+	clientMaxSize  int64
+	clientBundles  string
+)
+
+var clientsCmd = &cobra.Command{
+	Use:   "clients",
+	Short: "List torrent clients and the releases each one takes",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		clients := cfg.ExternalClients()
+		if len(clients) == 0 {
+			fmt.Println("📦 Using the internal client. Use 'clients add <name>' to add an external one.")
+			return
+		}
+
+		fmt.Println("📦 Torrent clients, preferred first:")
+		for _, tc := range clients {
+			primary := ""
+			if tc.ClientName() == cfg.TorrentClient.ClientName() {
+				primary = " (primary)"
+			}
+			fmt.Printf("   - %s%s: %s at %s, priority %d, takes %s\n",
+				ui.StyleFactory(tc.ClientName(), ui.Style.Pink), primary, tc.Type,
+				ui.StyleFactory(tc.URL, ui.Style.LBlue), tc.Priority, describeRules(tc.Rules))
+		}
+	},
+}
+
+var clientsAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add or replace a torrent client, e.g 'clients add big --type qbittorrent --url http://nas:8080 --min-size 10000'",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tc := shared.TorrentClientConfig{
+			Name:     args[0],
+			Type:     clientType,
+			URL:      clientURL,
+			Username: clientUsername,
+			Password: clientPassword,
+			Priority: clientPriority,
+			Rules: shared.ClientRules{
+				Resolutions: clientRes,
+				MinSizeMB:   clientMinSize,
+				MaxSizeMB:   clientMaxSize,
+				Bundles:     clientBundles,
+			},
+		}
+		if !tc.External() {
+			fmt.Println("❌ --type must be an external client, e.g qbittorrent, deluge or transmission")
+			return
+		}
+
+		cfg := shared.LoadConfig()
+		cfg.SetClient(tc)
+		if err := cfg.ValidateClients(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		if version, err := downloader.TestConnection(tc); err != nil {
+			fmt.Printf("⚠️  Could not connect to %s: %v\n", tc.ClientName(), err)
+		} else {
+			fmt.Printf("🔌 Connected to %s\n", version)
+		}
+
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Added %s, it takes %s\n", tc.ClientName(), describeRules(tc.Rules))
+	},
+}
+
+var clientsRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a torrent client. Its downloads move to the primary client",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		if !cfg.RemoveClient(args[0]) {
+			fmt.Printf("⚠️  No client named %s. The primary client is changed in the web settings.\n", args[0])
+			return
+		}
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Removed %s\n", args[0])
+	},
+}
+
+// e.g "1080p, up to 4000 MB, no bundles"
+func describeRules(r shared.ClientRules) string {
+	var parts []string
+	if len(r.Resolutions) > 0 {
+		parts = append(parts, strings.Join(r.Resolutions, "/"))
+	}
+	if r.MinSizeMB > 0 {
+		parts = append(parts, fmt.Sprintf("from %d MB", r.MinSizeMB))
+	}
+	if r.MaxSizeMB > 0 {
+		parts = append(parts, fmt.Sprintf("up to %d MB", r.MaxSizeMB))
+	}
+	switch r.Bundles {
+	case shared.BundlesOnly:
+		parts = append(parts, "bundles only")
+	case shared.BundlesNever:
+		parts = append(parts, "no bundles")
+	}
+
+	if len(parts) == 0 {
+		return "anything"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	clientsAddCmd.Flags().StringVarP(&clientType, "type", "t", "", "Client type: qbittorrent, deluge, transmission, aria2, rtorrent or blackhole")
+	clientsAddCmd.Flags().StringVarP(&clientURL, "url", "u", "", "Client URL, e.g http://localhost:8080")
+	clientsAddCmd.Flags().StringVar(&clientUsername, "username", "", "Client username")
+	clientsAddCmd.Flags().StringVar(&clientPassword, "password", "", "Client password")
+	clientsAddCmd.Flags().IntVarP(&clientPriority, "priority", "p", 0, "Lower is tried first when several clients take a release")
+	clientsAddCmd.Flags().StringSliceVarP(&clientRes, "resolution", "r", nil, "Only take these resolutions, e.g 1080p")
+	clientsAddCmd.Flags().Int64Var(&clientMinSize, "min-size", 0, "Only take releases of at least this many MB, 0 has no minimum")
+	clientsAddCmd.Flags().Int64Var(&clientMaxSize, "max-size", 0, "Only take releases of at most this many MB, 0 has no maximum")
+	clientsAddCmd.Flags().StringVar(&clientBundles, "bundles", "", "'only' for whole arc releases only, 'never' to skip them")
+
+	clientsCmd.AddCommand(clientsAddCmd, clientsRemoveCmd)
+	rootCmd.AddCommand(clientsCmd)
+}
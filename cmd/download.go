@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 
+	"opforjellyfin/internal/downloader"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
@@ -49,7 +51,10 @@ var downloadCmd = &cobra.Command{
 
 		// stop spinner
 		spinner.Stop()
+		printLowSpace(cfg)
+
 		var matches []shared.TorrentEntry
+		var fitting []*shared.TorrentDownload
 		for _, arg := range args {
 			num, err := strconv.Atoi(arg)
 			if err != nil {
@@ -82,6 +87,15 @@ var downloadCmd = &cobra.Command{
 				match.ChapterRange = forceKey
 			}
 
+			// the session downloads with the internal client, next to the matches before it
+			release := match.Release()
+			release.Size = downloader.ReleaseSize(context.Background(), *match, match.Source(cfg.Source.BaseURL))
+			if err := downloader.CheckSpace(release, shared.TorrentClientConfig{}, cfg, fitting); err != nil {
+				logger.Log(true, "❌ Skipping %s: %v", match.TorrentName, err)
+				continue
+			}
+			fitting = append(fitting, &shared.TorrentDownload{Quality: match.Quality, TotalSize: release.Size})
+
 			dKey := ui.StyleFactory(fmt.Sprintf("%4d", match.DownloadKey), ui.Style.Pink)
 			title := ui.StyleFactory(match.TorrentName, ui.Style.LBlue)
 
@@ -96,7 +110,7 @@ var downloadCmd = &cobra.Command{
 		}
 
 		// outsourced to monitoring function
-		torrent.HandleDownloadSession(matches, cfg.TargetDir)
+		torrent.HandleDownloadSession(matches, cfg)
 
 	},
 }
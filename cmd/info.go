@@ -42,6 +42,10 @@ var infoCmd = &cobra.Command{
 			return
 		}
 
+		if len(cfg.Libraries) > 0 {
+			listLibraries(cfg)
+		}
+
 		if verboseInfo {
 			fmt.Printf("📡 Torrent Provider: %s\n", cfg.Source.BaseURL)
 			fmt.Printf("🐙 Metadata Source:  https://github.com/%s\n", cfg.GitHubRepo)
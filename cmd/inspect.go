@@ -0,0 +1,88 @@
+// cmd/inspect.go
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"opforjellyfin/internal/scraper"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"opforjellyfin/internal/ui"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <downloadKey>",
+	Short: "Show the files in a torrent and where they would be placed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Printf("❌ Invalid download key: %s\n", args[0])
+			return
+		}
+
+		cfg := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			fmt.Println("⚠️  No target directory set. Use 'setDir' first.")
+			return
+		}
+
+		spinner := ui.NewSpinner("🔍 Fetching torrent.. ", ui.Animations["MetaFetcher"])
+
+		torrentList, err := scraper.FetchTorrents(cfg)
+		if err != nil {
+			spinner.Stop()
+			fmt.Printf("❌ Error scraping torrents. Site inaccessible? %v\n", err)
+			return
+		}
+
+		entry := scraper.FindByDownloadKey(torrentList, key)
+		if entry == nil {
+			spinner.Stop()
+			fmt.Printf("⚠️  No torrent found for key %d\n", key)
+			return
+		}
+
+		preview, err := torrent.PreviewTorrent(context.Background(), *entry, cfg)
+		spinner.Stop()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		fmt.Printf("📦 %s\n", ui.StyleFactory(preview.Title, ui.Style.LBlue))
+		fmt.Printf("   Chapters %s | %s | %s\n\n", preview.ChapterRange, preview.Quality, sizeMB(preview.TotalSize))
+
+		unmatched := 0
+		for _, f := range preview.Files {
+			switch {
+			case !f.Video:
+				fmt.Printf("   ⏭️  %s (%s) not a video, skipped\n", f.Path, sizeMB(f.Size))
+			case !f.Matched:
+				unmatched++
+				fmt.Printf("   ⚠️  %s (%s) %s\n", f.Path, sizeMB(f.Size), ui.StyleFactory("no matching episode", ui.Style.Pink))
+			default:
+				haveMark := ""
+				if f.Have {
+					haveMark = " ✅ have"
+				}
+				fmt.Printf("   🎞️  %s (%s)\n      → %s%s\n", f.Path, sizeMB(f.Size), f.Target, haveMark)
+			}
+		}
+
+		if unmatched > 0 {
+			fmt.Printf("\n⚠️  %d video(s) won't be placed. Try 'sync' if this is a new release.\n", unmatched)
+		}
+	},
+}
+
+func sizeMB(n int64) string {
+	return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024))
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
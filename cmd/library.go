@@ -0,0 +1,139 @@
+// cmd/library.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/flags"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	libraryResolutions []string
+	libraryPlacement   = flags.StringChoice(shared.PlacementStrategies)
+)
+
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Manage target libraries, e.g a 1080p and a 480p copy",
+	Run: func(cmd *cobra.Command, args []string) {
+		listLibraries(shared.LoadConfig())
+	},
+}
+
+var libraryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured libraries",
+	Run: func(cmd *cobra.Command, args []string) {
+		listLibraries(shared.LoadConfig())
+	},
+}
+
+var libraryAddCmd = &cobra.Command{
+	Use:   "add <name> <path>",
+	Short: "Add a library with its own target directory",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		abs, err := filepath.Abs(args[1])
+		if err != nil {
+			fmt.Printf("❌ Invalid directory: %v\n", err)
+			return
+		}
+
+		cfg := shared.LoadConfig()
+
+		// keep the current target directory as a library, it would stop receiving downloads otherwise
+		if len(cfg.Libraries) == 0 {
+			cfg.Libraries = cfg.AllLibraries()
+		}
+
+		for _, lib := range cfg.Libraries {
+			if lib.Name == name {
+				fmt.Printf("⚠️  A library named %s already exists.\n", name)
+				return
+			}
+		}
+
+		cfg.Libraries = append(cfg.Libraries, shared.LibraryConfig{
+			Name:        name,
+			TargetDir:   abs,
+			Resolutions: libraryResolutions,
+			Placement:   libraryPlacement.Value,
+		})
+
+		if cfg.TargetDir == "" {
+			cfg.TargetDir = abs
+		}
+
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Added library %s → %s\n", name, abs)
+		fmt.Println("ℹ️  Run 'sync' to copy metadata into it.")
+	},
+}
+
+var libraryRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a library (files are kept)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+
+		var kept []shared.LibraryConfig
+		for _, lib := range cfg.Libraries {
+			if lib.Name != args[0] {
+				kept = append(kept, lib)
+			}
+		}
+
+		if len(kept) == len(cfg.Libraries) {
+			fmt.Printf("⚠️  No library named %s.\n", args[0])
+			return
+		}
+
+		cfg.Libraries = kept
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Removed library %s\n", args[0])
+	},
+}
+
+func listLibraries(cfg shared.Config) {
+	libs := cfg.AllLibraries()
+	if len(libs) == 0 {
+		fmt.Println("⚠️  No libraries set. Use 'setDir <path>' or 'library add <name> <path>'.")
+		return
+	}
+
+	fmt.Println("📚 Libraries:")
+	for _, lib := range libs {
+		resolutions := "all"
+		if len(lib.Resolutions) > 0 {
+			resolutions = strings.Join(lib.Resolutions, ", ")
+		}
+		placement := lib.Placement
+		if placement == "" {
+			placement = shared.PlacementHardlink
+		}
+
+		primary := ""
+		if filepath.Clean(lib.TargetDir) == filepath.Clean(cfg.TargetDir) {
+			primary = " (primary)"
+		}
+
+		fmt.Printf("   - %s%s: %s | %s | %s\n", ui.StyleFactory(lib.Name, ui.Style.Pink), primary, ui.StyleFactory(lib.TargetDir, ui.Style.LBlue), resolutions, placement)
+	}
+}
+
+func init() {
+	libraryAddCmd.Flags().StringSliceVarP(&libraryResolutions, "resolutions", "r", nil, "Allowed resolutions, e.g 1080p,720p. Default all")
+	libraryAddCmd.Flags().VarP(libraryPlacement, "placement", "p", "Placement strategy: hardlink, copy or symlink. Default hardlink")
+
+	libraryCmd.AddCommand(libraryListCmd, libraryAddCmd, libraryRemoveCmd)
+	rootCmd.AddCommand(libraryCmd)
+}
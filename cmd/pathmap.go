@@ -0,0 +1,83 @@
+// cmd/pathmap.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var pathmapCmd = &cobra.Command{
+	Use:   "pathmap",
+	Short: "Map torrent client paths to local paths, for clients in another container",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkPathMappings(shared.LoadConfig())
+	},
+}
+
+var pathmapAddCmd = &cobra.Command{
+	Use:   "add <client path> <local path>",
+	Short: "Add a mapping, e.g 'pathmap add /downloads /data/torrents'",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		local, err := filepath.Abs(args[1])
+		if err != nil {
+			fmt.Printf("❌ Invalid directory: %v\n", err)
+			return
+		}
+
+		m := shared.PathMapping{Remote: args[0], Local: local}
+		if err := m.Validate(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		cfg := shared.LoadConfig()
+		cfg.SetPathMapping(m)
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Mapped %s → %s\n", m.Remote, m.Local)
+	},
+}
+
+var pathmapRemoveCmd = &cobra.Command{
+	Use:   "remove <client path>",
+	Short: "Remove a mapping",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		if !cfg.RemovePathMapping(args[0]) {
+			fmt.Printf("⚠️  No mapping for %s.\n", args[0])
+			return
+		}
+		shared.SaveConfig(cfg)
+
+		fmt.Printf("✅ Removed mapping for %s\n", args[0])
+	},
+}
+
+// lists mappings and checks every local path exists and is readable
+func checkPathMappings(cfg shared.Config) {
+	mappings := cfg.TorrentClient.PathMappings
+	if len(mappings) == 0 {
+		fmt.Println("⚠️  No path mappings. Use 'pathmap add <client path> <local path>'.")
+		return
+	}
+
+	fmt.Println("🗺️  Path mappings:")
+	for _, m := range mappings {
+		status := "✅"
+		if err := m.Validate(); err != nil {
+			status = "❌ " + err.Error()
+		}
+		fmt.Printf("   - %s → %s %s\n", ui.StyleFactory(m.Remote, ui.Style.Pink), ui.StyleFactory(m.Local, ui.Style.LBlue), status)
+	}
+}
+
+func init() {
+	pathmapCmd.AddCommand(pathmapAddCmd, pathmapRemoveCmd)
+	rootCmd.AddCommand(pathmapCmd)
+}
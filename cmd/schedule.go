@@ -0,0 +1,103 @@
+// cmd/schedule.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/shared"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleWindows    []string
+	scheduleConcurrent int
+	scheduleDownload   int
+	scheduleUpload     int
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Show download windows, concurrency and bandwidth limits",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		sched := cfg.Schedule
+
+		windows := "any time"
+		if len(sched.Windows) > 0 {
+			windows = strings.Join(sched.Windows, ", ")
+		}
+		state := "open"
+		if !sched.Open(time.Now()) {
+			state = "closed"
+		}
+
+		fmt.Printf("🕐 Download windows: %s (%s now)\n", windows, state)
+		fmt.Printf("📥 Downloads at once: %d\n", sched.Concurrent())
+		fmt.Printf("📶 Internal client: down %s, up %s\n", kibLimit(cfg.TorrentClient.DownloadLimit), kibLimit(cfg.TorrentClient.UploadLimit))
+	},
+}
+
+var scheduleSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set download windows, concurrency or bandwidth limits",
+	Long:  "Set download windows, concurrency or bandwidth limits. Only the given flags change, e.g\n  opfor schedule set --window 01:00-07:00 --down 2048",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+
+		if cmd.Flags().Changed("window") {
+			sched := shared.ScheduleConfig{Windows: scheduleWindows}
+			if err := sched.Validate(); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				return
+			}
+			cfg.Schedule.Windows = scheduleWindows
+		}
+		if cmd.Flags().Changed("concurrent") {
+			cfg.Schedule.MaxConcurrent = scheduleConcurrent
+		}
+		if cmd.Flags().Changed("down") {
+			cfg.TorrentClient.DownloadLimit = scheduleDownload
+		}
+		if cmd.Flags().Changed("up") {
+			cfg.TorrentClient.UploadLimit = scheduleUpload
+		}
+
+		shared.SaveConfig(cfg)
+
+		fmt.Println("✅ Schedule saved. A running 'serve' picks it up after a restart.")
+	},
+}
+
+var scheduleClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Allow downloads at any time, with default concurrency and no bandwidth limits",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		cfg.Schedule = shared.ScheduleConfig{}
+		cfg.TorrentClient.DownloadLimit = 0
+		cfg.TorrentClient.UploadLimit = 0
+		shared.SaveConfig(cfg)
+
+		fmt.Println("✅ Schedule cleared.")
+	},
+}
+
+// e.g "512 KiB/s" or "unlimited"
+func kibLimit(kib int) string {
+	if kib <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d KiB/s", kib)
+}
+
+func init() {
+	scheduleSetCmd.Flags().StringSliceVarP(&scheduleWindows, "window", "w", nil, "Download windows in local time, e.g 01:00-07:00. Empty allows any time")
+	scheduleSetCmd.Flags().IntVarP(&scheduleConcurrent, "concurrent", "c", 0, "Downloads at once, 0 uses the default of 5")
+	scheduleSetCmd.Flags().IntVar(&scheduleDownload, "down", 0, "Internal client download limit in KiB/s, 0 is unlimited")
+	scheduleSetCmd.Flags().IntVar(&scheduleUpload, "up", 0, "Internal client upload limit in KiB/s, 0 is unlimited")
+
+	scheduleCmd.AddCommand(scheduleSetCmd, scheduleClearCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,141 @@
+// cmd/seed.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/downloader"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"opforjellyfin/internal/ui"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	seedRatio   float64
+	seedMinutes int
+	seedMode    string
+)
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Show seeding status and pending seed jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+
+		mode := cfg.Seeding.Mode
+		if mode == shared.SeedModeAny {
+			mode = "first target"
+		}
+		fmt.Printf("🎯 Policy: ratio %.2f, %d min, %s (0 = no target)\n", cfg.Seeding.Ratio, cfg.Seeding.Minutes, mode)
+
+		if cfg.Seeding.Enabled {
+			fmt.Println("🌱 Internal seeding is on.")
+		} else {
+			fmt.Println("🌱 Internal seeding is off. Use 'seed on' to enable it.")
+		}
+
+		if seeds := downloader.LoadClientSeeds(); len(seeds) > 0 {
+			fmt.Println("📤 Seeding in external clients, removed once the policy is reached:")
+			for _, seed := range seeds {
+				fmt.Printf("   - %s | %s | imported %s\n", ui.StyleFactory(seed.Title, ui.Style.LBlue),
+					cfg.ClientByName(seed.Client).ClientName(), seed.Imported.Format("2006-01-02 15:04"))
+			}
+		}
+
+		jobs := torrent.LoadSeedJobs()
+		if len(jobs) == 0 {
+			return
+		}
+
+		fmt.Println("📤 Pending seed jobs:")
+		for _, job := range jobs {
+			ratio := 0.0
+			if job.Size > 0 {
+				ratio = float64(job.Uploaded) / float64(job.Size)
+			}
+			seeded := time.Duration(job.SeededSeconds) * time.Second
+
+			fmt.Printf("   - %s | ratio %.2f | %s\n", ui.StyleFactory(job.Title, ui.Style.LBlue), ratio, seeded)
+		}
+	},
+}
+
+var seedOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Keep internal downloads seeding from the placed files",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+
+		cfg.Seeding.Enabled = true
+		if !setSeedingPolicy(cmd, &cfg) {
+			return
+		}
+
+		shared.SaveConfig(cfg)
+
+		fmt.Println("✅ Seeding enabled. Downloads keep seeding after the session, and in 'serve'.")
+	},
+}
+
+var seedOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Stop seeding new downloads (pending jobs are kept)",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		cfg.Seeding.Enabled = false
+		shared.SaveConfig(cfg)
+
+		fmt.Println("✅ Seeding disabled.")
+	},
+}
+
+var seedSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set the seeding policy, also used to remove finished torrents from external clients",
+	Long:  "Set the seeding policy. Only the given flags change, e.g\n  opfor seed set --ratio 1 --minutes 60 --mode both",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		if !setSeedingPolicy(cmd, &cfg) {
+			return
+		}
+
+		shared.SaveConfig(cfg)
+
+		fmt.Println("✅ Seeding policy saved. A running 'serve' picks it up after a restart.")
+	},
+}
+
+// applies the changed policy flags, false if the result is invalid
+func setSeedingPolicy(cmd *cobra.Command, cfg *shared.Config) bool {
+	if cmd.Flags().Changed("ratio") {
+		cfg.Seeding.Ratio = seedRatio
+	}
+	if cmd.Flags().Changed("minutes") {
+		cfg.Seeding.Minutes = seedMinutes
+	}
+	if cmd.Flags().Changed("mode") {
+		cfg.Seeding.Mode = seedMode
+		if seedMode == "any" {
+			cfg.Seeding.Mode = shared.SeedModeAny
+		}
+	}
+
+	if err := cfg.Seeding.Validate(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return false
+	}
+	return true
+}
+
+func init() {
+	for _, c := range []*cobra.Command{seedOnCmd, seedSetCmd} {
+		c.Flags().Float64VarP(&seedRatio, "ratio", "r", 0, "Stop after uploading ratio x size, 0 disables")
+		c.Flags().IntVarP(&seedMinutes, "minutes", "m", 0, "Stop after seeding this many minutes, 0 disables")
+		c.Flags().StringVar(&seedMode, "mode", "", "Targets to reach: ratio, time, both or any (first reached)")
+	}
+
+	seedCmd.AddCommand(seedOnCmd, seedOffCmd, seedSetCmd)
+	rootCmd.AddCommand(seedCmd)
+}
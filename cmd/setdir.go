@@ -30,7 +30,8 @@ func SetDir(dir string, force bool) {
 	}
 
 	cfg := shared.LoadConfig()
-	cfg.TargetDir = abs
+
+	cfg.SetTargetDir(abs)
 	shared.SaveConfig(cfg)
 
 	fmt.Println("✅ Default target directory set to:", abs)
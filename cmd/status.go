@@ -3,6 +3,7 @@ package cmd
 
 import (
 	"fmt"
+	"opforjellyfin/internal/downloader"
 	"opforjellyfin/internal/shared"
 
 	"github.com/spf13/cobra"
@@ -12,6 +13,8 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show currently active downloads",
 	Run: func(cmd *cobra.Command, args []string) {
+		printLowSpace(shared.LoadConfig())
+
 		downloads := shared.GetActiveDownloads()
 		if len(downloads) == 0 {
 			fmt.Println("📭 No active downloads.")
@@ -24,6 +27,13 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+// warns about client and library disks running full
+func printLowSpace(cfg shared.Config) {
+	for _, d := range downloader.LowSpace(cfg) {
+		fmt.Printf("⚠️  Low disk space on %s (%s): %s free\n", d.Name, d.Path, shared.FormatGB(d.Free))
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
 }
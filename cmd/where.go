@@ -0,0 +1,56 @@
+// cmd/where.go
+package cmd
+
+import (
+	"fmt"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var whereCmd = &cobra.Command{
+	Use:   "where <chapter|range>",
+	Short: "Show which arc and episode covers a manga chapter",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			fmt.Println("⚠️  No target directory set. Use 'setDir' first.")
+			return
+		}
+
+		start, end, err := shared.ParseChapterQuery(args[0])
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		results := metadata.LookupChapters(start, end)
+		if len(results) == 0 {
+			fmt.Printf("📭 No episode found for chapter(s) %s. Try 'sync' if this is a new release.\n", args[0])
+			return
+		}
+
+		for _, r := range results {
+			haveMark := "❌"
+			if r.HaveVideo {
+				haveMark = "✅"
+			}
+
+			arc := ui.StyleFactory(r.Arc, ui.Style.LBlue)
+			season := ui.StyleFactory(fmt.Sprintf("%d", r.SeasonNumber), ui.Style.Pink)
+
+			fmt.Printf("📍 %s (Season %s) [%s] Have? %s\n", arc, season, r.ChapterRange, haveMark)
+			if r.Episode != "" {
+				fmt.Printf("   🎞️  %s\n", r.Episode)
+			}
+			fmt.Printf("   📂 %s\n", r.Path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whereCmd)
+}
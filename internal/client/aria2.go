@@ -0,0 +1,465 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// aria2 knows downloads by GID, opforjellyfin by infohash, so GIDs are looked up and cached here.
+// it has no categories or labels, ListTorrents returns every torrent. torrents are never adopted
+// from it, in a shared daemon those belong to someone else
+type Aria2Client struct {
+	config shared.TorrentClientConfig
+	client *http.Client
+
+	mu   sync.Mutex
+	gids map[string]string // infohash -> gid
+}
+
+type aria2Request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type aria2Response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// a download as returned by tellStatus, aria2 sends numbers as strings
+type aria2Status struct {
+	GID             string   `json:"gid"`
+	Status          string   `json:"status"` // active, waiting, paused, error, complete or removed
+	TotalLength     string   `json:"totalLength"`
+	CompletedLength string   `json:"completedLength"`
+	UploadLength    string   `json:"uploadLength"`
+	DownloadSpeed   string   `json:"downloadSpeed"`
+	UploadSpeed     string   `json:"uploadSpeed"`
+	NumSeeders      string   `json:"numSeeders"`
+	Connections     string   `json:"connections"`
+	Dir             string   `json:"dir"`
+	InfoHash        string   `json:"infoHash"`
+	ErrorMessage    string   `json:"errorMessage"`
+	FollowedBy      []string `json:"followedBy"` // set on finished magnet metadata downloads
+	Bittorrent      struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"` // missing while a magnet's metadata is fetched
+	} `json:"bittorrent"`
+}
+
+type aria2File struct {
+	Index           string `json:"index"` // starts at 1
+	Path            string `json:"path"`  // absolute, as aria2 sees it
+	Length          string `json:"length"`
+	CompletedLength string `json:"completedLength"`
+}
+
+var aria2StatusKeys = []string{"gid", "status", "totalLength", "completedLength", "uploadLength", "downloadSpeed", "uploadSpeed", "numSeeders", "connections", "dir", "infoHash", "errorMessage", "followedBy", "bittorrent"}
+
+// the password is used as the RPC secret token
+func NewAria2Client(cfg shared.TorrentClientConfig) (*Aria2Client, error) {
+	client := &Aria2Client{
+		config: cfg,
+		client: &http.Client{Timeout: requestTimeout},
+		gids:   make(map[string]string),
+	}
+
+	if err := client.TestConnection(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	return client, nil
+}
+
+func (a *Aria2Client) call(method string, result any, params ...any) error {
+	if a.config.Password != "" {
+		params = append([]any{"token:" + a.config.Password}, params...)
+	}
+	if params == nil {
+		params = []any{}
+	}
+
+	body, err := json.Marshal(aria2Request{JSONRPC: "2.0", ID: "opforjellyfin", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(a.config.URL, "/")
+	if !strings.HasSuffix(endpoint, "/jsonrpc") {
+		endpoint += "/jsonrpc"
+	}
+
+	// reads are retried when the connection fails
+	idempotent := strings.HasPrefix(method, "aria2.tell") || strings.HasPrefix(method, "aria2.get")
+	var httpResp *http.Response
+	err = withRetry(idempotent, func() error {
+		httpResp, err = a.client.Post(endpoint, "application/json", bytes.NewReader(body))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	// errors come with a 400 but still as JSON-RPC
+	var resp aria2Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("%s: status %d: %w", method, httpResp.StatusCode, err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s: %s", method, resp.Error.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (a *Aria2Client) TestConnection() error {
+	return a.call("aria2.getVersion", nil)
+}
+
+func (a *Aria2Client) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	hash := shared.InfoHashFromMagnet(torrentURL)
+	if hash == "" {
+		// aria2 only knows the hash of a .torrent URL once it fetched it
+		data, err := FetchTorrentFile(ctx, torrentURL)
+		if err != nil {
+			return "", err
+		}
+		return a.AddTorrentFile(ctx, data, opts)
+	}
+
+	var gid string
+	if err := a.call("aria2.addUri", &gid, []string{torrentURL}, aria2Options(opts)); err != nil {
+		return a.existing(hash, err)
+	}
+
+	a.remember(hash, gid)
+	return hash, nil
+}
+
+func (a *Aria2Client) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	hash, err := InfoHashOf(data)
+	if err != nil {
+		return "", err
+	}
+
+	var gid string
+	if err := a.call("aria2.addTorrent", &gid, base64.StdEncoding.EncodeToString(data), []string{}, aria2Options(opts)); err != nil {
+		return a.existing(hash, err)
+	}
+
+	a.remember(hash, gid)
+	return hash, nil
+}
+
+// aria2 refuses torrents it already has, which is fine if it is really there
+func (a *Aria2Client) existing(hash string, addErr error) (string, error) {
+	if _, err := a.status(hash); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", addErr)
+	}
+	return hash, nil
+}
+
+// aria2 has no labels and never downloads torrent pieces in order
+func aria2Options(opts AddOptions) map[string]string {
+	options := map[string]string{}
+	if opts.SavePath != "" {
+		options["dir"] = opts.SavePath
+	}
+	if opts.SeedRatio > 0 {
+		options["seed-ratio"] = strconv.FormatFloat(opts.SeedRatio, 'f', 2, 64)
+	}
+	if opts.SeedMinutes > 0 {
+		options["seed-time"] = strconv.Itoa(opts.SeedMinutes)
+	}
+	if opts.Paused {
+		options["pause"] = "true"
+	}
+	return options
+}
+
+func (a *Aria2Client) remember(hash, gid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gids[strings.ToLower(hash)] = gid
+}
+
+func (a *Aria2Client) forget(hash string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.gids, strings.ToLower(hash))
+}
+
+// the download of a torrent. a magnet's metadata download hands over to a new GID, so that one is skipped
+func (a *Aria2Client) status(hash string) (*aria2Status, error) {
+	hash = strings.ToLower(hash)
+
+	a.mu.Lock()
+	gid, ok := a.gids[hash]
+	a.mu.Unlock()
+
+	if ok {
+		var s aria2Status
+		if err := a.call("aria2.tellStatus", &s, gid, aria2StatusKeys); err == nil && len(s.FollowedBy) == 0 && s.Status != "removed" {
+			return &s, nil
+		}
+	}
+
+	downloads, err := a.tellAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range downloads {
+		s := &downloads[i]
+		if strings.ToLower(s.InfoHash) == hash && len(s.FollowedBy) == 0 && s.Status != "removed" {
+			a.remember(hash, s.GID)
+			return s, nil
+		}
+	}
+
+	return nil, ErrTorrentNotFound
+}
+
+// every download aria2 knows, running, queued and stopped
+func (a *Aria2Client) tellAll() ([]aria2Status, error) {
+	var active, waiting, stopped []aria2Status
+	if err := a.call("aria2.tellActive", &active, aria2StatusKeys); err != nil {
+		return nil, err
+	}
+	if err := a.call("aria2.tellWaiting", &waiting, 0, 1000, aria2StatusKeys); err != nil {
+		return nil, err
+	}
+	if err := a.call("aria2.tellStopped", &stopped, 0, 1000, aria2StatusKeys); err != nil {
+		return nil, err
+	}
+
+	return append(append(active, waiting...), stopped...), nil
+}
+
+func (a *Aria2Client) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
+	s, err := a.status(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.torrentStatus(), nil
+}
+
+// aria2 can't filter by hash, one listing of everything is still fewer calls than a status each
+func (a *Aria2Client) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	if len(torrentIDs) == 0 {
+		return statuses, nil
+	}
+
+	wanted := make(map[string]bool, len(torrentIDs))
+	for _, id := range torrentIDs {
+		wanted[strings.ToLower(id)] = true
+	}
+
+	downloads, err := a.tellAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range downloads {
+		s := &downloads[i]
+		hash := strings.ToLower(s.InfoHash)
+		if !wanted[hash] || len(s.FollowedBy) > 0 || s.Status == "removed" {
+			continue
+		}
+		a.remember(hash, s.GID)
+		statuses[hash] = s.torrentStatus()
+	}
+	return statuses, nil
+}
+
+func (a *Aria2Client) ListTorrents() ([]TorrentStatus, error) {
+	downloads, err := a.tellAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []TorrentStatus
+	for _, s := range downloads {
+		if s.InfoHash == "" || len(s.FollowedBy) > 0 || s.Status == "removed" || s.Bittorrent.Info.Name == "" {
+			continue
+		}
+		statuses = append(statuses, *s.torrentStatus())
+	}
+	return statuses, nil
+}
+
+func (s *aria2Status) torrentStatus() *TorrentStatus {
+	total := aria2Int(s.TotalLength)
+	completed := aria2Int(s.CompletedLength)
+
+	status := &TorrentStatus{
+		ID:            s.InfoHash,
+		Name:          s.Bittorrent.Info.Name,
+		State:         s.Status,
+		Downloaded:    completed,
+		TotalSize:     total,
+		DownloadSpeed: aria2Int(s.DownloadSpeed),
+		UploadSpeed:   aria2Int(s.UploadSpeed),
+		Uploaded:      aria2Int(s.UploadLength),
+		Paused:        s.Status == "paused",
+		Seeders:       int(aria2Int(s.NumSeeders)),
+		Peers:         int(aria2Int(s.Connections)),
+		SavePath:      s.Dir,
+		Error:         s.ErrorMessage,
+		// without info it is still fetching a magnet's metadata
+		IsComplete: s.Bittorrent.Info.Name != "" && total > 0 && completed >= total,
+	}
+	if total > 0 {
+		status.Progress = float64(completed) / float64(total) * 100
+	}
+
+	return status
+}
+
+func aria2Int(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func (a *Aria2Client) RemoveTorrent(torrentID string, deleteFiles bool) error {
+	s, err := a.status(torrentID)
+	if err != nil {
+		return err
+	}
+
+	switch s.Status {
+	case "active", "waiting", "paused":
+		if err := a.call("aria2.forceRemove", nil, s.GID); err != nil {
+			return err
+		}
+	}
+
+	// stopped downloads stay listed until their result is removed
+	if err := a.call("aria2.removeDownloadResult", nil, s.GID); err != nil {
+		logger.Log(false, "Could not clear aria2 result of %s: %v", s.GID, err)
+	}
+	a.forget(torrentID)
+
+	if deleteFiles {
+		return a.deleteData(s)
+	}
+	return nil
+}
+
+// aria2 can't delete data over RPC, so it is deleted here, through the path mappings
+func (a *Aria2Client) deleteData(s *aria2Status) error {
+	name := s.Bittorrent.Info.Name
+	if name == "" || s.Dir == "" {
+		return nil
+	}
+
+	root, err := deleteMappedData(a.config.PathMappings, strings.TrimRight(s.Dir, `/\`)+"/"+name)
+	if err != nil {
+		return err
+	}
+	os.Remove(root + ".aria2") // control file, only there if unfinished
+
+	return nil
+}
+
+func (a *Aria2Client) PauseTorrent(torrentID string) error {
+	s, err := a.status(torrentID)
+	if err != nil {
+		return err
+	}
+	return a.call("aria2.pause", nil, s.GID)
+}
+
+func (a *Aria2Client) ResumeTorrent(torrentID string) error {
+	s, err := a.status(torrentID)
+	if err != nil {
+		return err
+	}
+	return a.call("aria2.unpause", nil, s.GID)
+}
+
+func (a *Aria2Client) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	s, err := a.status(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Bittorrent.Info.Name == "" {
+		return nil, nil // magnet metadata not there yet
+	}
+
+	var files []aria2File
+	if err := a.call("aria2.getFiles", &files, s.GID); err != nil {
+		return nil, err
+	}
+
+	dir := strings.TrimRight(s.Dir, `/\`)
+	result := make([]TorrentFile, 0, len(files))
+	for _, f := range files {
+		length := aria2Int(f.Length)
+
+		tf := TorrentFile{
+			Index: int(aria2Int(f.Index)) - 1,
+			Path:  strings.TrimLeft(strings.TrimPrefix(f.Path, dir), `/\`),
+			Size:  length,
+		}
+		if length > 0 {
+			tf.Progress = float64(aria2Int(f.CompletedLength)) / float64(length) * 100
+		}
+
+		result = append(result, tf)
+	}
+
+	return result, nil
+}
+
+func (a *Aria2Client) SetFilePriorities(torrentID string, wanted []bool) error {
+	var selected []string
+	for i, w := range wanted {
+		if w {
+			selected = append(selected, strconv.Itoa(i+1))
+		}
+	}
+	// nothing selected means every file to aria2
+	if len(selected) == 0 {
+		return nil
+	}
+
+	s, err := a.status(torrentID)
+	if err != nil {
+		return err
+	}
+	return a.call("aria2.changeOption", nil, s.GID, map[string]string{"select-file": strings.Join(selected, ",")})
+}
+
+func (a *Aria2Client) GetClientInfo() (*ClientInfo, error) {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := a.call("aria2.getVersion", &version); err != nil {
+		return nil, err
+	}
+
+	// aria2 can't tell free space, it stays unknown
+	return &ClientInfo{
+		Version: version.Version,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"opforjellyfin/internal/shared"
+	"testing"
+)
+
+const testHash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+// a fake aria2 with a finished magnet metadata download followed by the real one
+func fakeAria2(t *testing.T, calls *[]string) *httptest.Server {
+	downloads := []map[string]any{
+		{"gid": "meta", "status": "complete", "infoHash": testHash, "followedBy": []string{"real"}, "dir": "/downloads", "bittorrent": map[string]any{}},
+		{
+			"gid": "real", "status": "active", "infoHash": testHash, "dir": "/downloads",
+			"totalLength": "1000", "completedLength": "250", "uploadLength": "40", "numSeeders": "3", "connections": "5",
+			"bittorrent": map[string]any{"info": map[string]any{"name": "[One Pace][1-7] Romance Dawn [1080p]"}},
+		},
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req aria2Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		*calls = append(*calls, req.Method)
+
+		reply := func(result any) {
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		}
+
+		if len(req.Params) == 0 || req.Params[0] != "token:secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": 1, "message": "Unauthorized"}})
+			return
+		}
+
+		switch req.Method {
+		case "aria2.getVersion":
+			reply(map[string]any{"version": "1.37.0"})
+		case "aria2.addUri":
+			reply("meta")
+		case "aria2.tellStatus":
+			for _, d := range downloads {
+				if d["gid"] == req.Params[1] {
+					reply(d)
+					return
+				}
+			}
+			reply(nil)
+		case "aria2.tellActive":
+			reply(downloads[1:])
+		case "aria2.tellWaiting":
+			reply([]any{})
+		case "aria2.tellStopped":
+			reply(downloads[:1])
+		case "aria2.getFiles":
+			reply([]map[string]any{
+				{"index": "1", "path": "/downloads/[One Pace][1-7] Romance Dawn [1080p]/ep1.mkv", "length": "600", "completedLength": "600"},
+				{"index": "2", "path": "/downloads/[One Pace][1-7] Romance Dawn [1080p]/ep2.mkv", "length": "400", "completedLength": "0"},
+			})
+		default:
+			reply("OK")
+		}
+	}))
+}
+
+func TestAria2Client(t *testing.T) {
+	var calls []string
+	srv := fakeAria2(t, &calls)
+	defer srv.Close()
+
+	if _, err := NewAria2Client(shared.TorrentClientConfig{URL: srv.URL, Password: "wrong"}); err == nil {
+		t.Fatal("expected an error for a wrong secret")
+	}
+
+	c, err := NewAria2Client(shared.TorrentClientConfig{URL: srv.URL, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := c.AddTorrent(context.Background(), "magnet:?xt=urn:btih:"+testHash, AddOptions{SeedRatio: 0.6})
+	if err != nil || hash != testHash {
+		t.Fatalf("AddTorrent = %q, %v", hash, err)
+	}
+
+	// the metadata download handed over, the status comes from the followed download
+	status, err := c.GetTorrentStatus(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Progress != 25 || status.Uploaded != 40 || status.Seeders != 3 || status.IsComplete {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	files, err := c.GetTorrentFiles(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Index != 0 || files[0].Path != "[One Pace][1-7] Romance Dawn [1080p]/ep1.mkv" || files[0].Progress != 100 {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	torrents, err := c.ListTorrents()
+	if err != nil || len(torrents) != 1 {
+		t.Errorf("ListTorrents = %+v, %v", torrents, err)
+	}
+
+	calls = nil
+	if err := c.RemoveTorrent(hash, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) < 2 || calls[len(calls)-2] != "aria2.forceRemove" || calls[len(calls)-1] != "aria2.removeDownloadResult" {
+		t.Errorf("unexpected calls for remove: %v", calls)
+	}
+}
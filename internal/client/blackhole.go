@@ -0,0 +1,421 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// a torrent is complete once its files stopped changing for this long
+const blackholeSettle = 2 * time.Minute
+
+// files clients write while downloading, a magnet with any of these left isn't done
+var partialSuffixes = []string{".part", ".!qb", ".!ut", ".!bt", ".aria2", ".crdownload"}
+
+// BlackholeClient hands torrents to a client we can't talk to, through its watch folder.
+// what shows up in the completed folder is the only status there is.
+type BlackholeClient struct {
+	config shared.TorrentClientConfig
+}
+
+// a torrent written to the watch folder, kept in the config dir since clients move or delete what they pick up
+type blackholeEntry struct {
+	Hash     string          `json:"hash"`
+	Name     string          `json:"name"`            // file or folder the client creates in the completed folder
+	Files    []blackholeFile `json:"files,omitempty"` // from the .torrent, magnets have none
+	Added    time.Time       `json:"added"`
+	Size     int64           `json:"size"`     // size on disk at the last check
+	Modified time.Time       `json:"modified"` // newest file change at the last check
+	Changed  time.Time       `json:"changed"`  // when Size or Modified last changed
+}
+
+type blackholeFile struct {
+	Path string `json:"path"` // inside the torrent, without its name
+	Size int64  `json:"size"`
+}
+
+func NewBlackholeClient(cfg shared.TorrentClientConfig) (*BlackholeClient, error) {
+	client := &BlackholeClient{config: cfg}
+
+	if err := client.TestConnection(); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func blackholeDir() string {
+	return filepath.Join(shared.ConfigDir(), "blackhole")
+}
+
+func blackholeEntryPath(hash string) string {
+	return filepath.Join(blackholeDir(), strings.ToLower(hash)+".json")
+}
+
+func (b *BlackholeClient) TestConnection() error {
+	for _, folder := range []struct{ name, dir string }{{"watch", b.config.WatchDir}, {"completed", b.config.CompletedDir}} {
+		name, dir := folder.name, folder.dir
+		if dir == "" {
+			return fmt.Errorf("no %s folder set", name)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("%s folder: %w", name, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s folder %s is not a directory", name, dir)
+		}
+	}
+	return nil
+}
+
+func (b *BlackholeClient) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	hash := shared.InfoHashFromMagnet(torrentURL)
+	if hash == "" {
+		data, err := FetchTorrentFile(ctx, torrentURL)
+		if err != nil {
+			return "", err
+		}
+		return b.AddTorrentFile(ctx, data, opts)
+	}
+
+	// clients name a magnet download after its dn, or the hash without one
+	name := hash
+	if query, err := url.ParseQuery(strings.TrimPrefix(torrentURL, "magnet:?")); err == nil && query.Get("dn") != "" {
+		name = query.Get("dn")
+	}
+
+	if err := b.drop(hash+".magnet", []byte(torrentURL)); err != nil {
+		return "", err
+	}
+	return hash, b.save(&blackholeEntry{Hash: hash, Name: name, Added: time.Now()})
+}
+
+// the client decides where and how to download, AddOptions don't apply
+func (b *BlackholeClient) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	meta, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent: %w", err)
+	}
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent: %w", err)
+	}
+	hash := meta.HashInfoBytes().HexString()
+
+	entry := &blackholeEntry{Hash: hash, Name: info.BestName(), Added: time.Now()}
+	if info.IsDir() {
+		for _, f := range info.UpvertedFiles() {
+			entry.Files = append(entry.Files, blackholeFile{Path: strings.Join(f.BestPath(), "/"), Size: f.Length})
+		}
+	} else {
+		entry.Files = []blackholeFile{{Path: "", Size: info.TotalLength()}}
+	}
+
+	if err := b.drop(hash+".torrent", data); err != nil {
+		return "", err
+	}
+	return hash, b.save(entry)
+}
+
+// writes into the watch folder through a temp file, so the client never picks up half a file
+func (b *BlackholeClient) drop(name string, data []byte) error {
+	tmp := filepath.Join(b.config.WatchDir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not write to the watch folder: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(b.config.WatchDir, name))
+}
+
+func (b *BlackholeClient) save(entry *blackholeEntry) error {
+	if err := shared.CreateDirectory(blackholeDir()); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(blackholeEntryPath(entry.Hash), data, 0644)
+}
+
+func (b *BlackholeClient) load(hash string) (*blackholeEntry, error) {
+	data, err := os.ReadFile(blackholeEntryPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTorrentNotFound
+		}
+		return nil, err
+	}
+
+	entry := &blackholeEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("invalid blackhole entry %s: %w", hash, err)
+	}
+	return entry, nil
+}
+
+// where the torrent's files end up, and the folder its file paths are relative to
+func (b *BlackholeClient) layout(entry *blackholeEntry) (root, savePath string, isDir bool) {
+	root = filepath.Join(b.config.CompletedDir, entry.Name)
+
+	if len(entry.Files) > 0 {
+		isDir = entry.Files[0].Path != ""
+	} else if info, err := os.Stat(root); err == nil {
+		isDir = info.IsDir()
+	}
+
+	if isDir {
+		return root, root, true
+	}
+	return root, b.config.CompletedDir, false
+}
+
+func (b *BlackholeClient) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
+	entry, err := b.load(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	return b.status(entry)
+}
+
+// everything is on local disk, there is nothing to batch
+func (b *BlackholeClient) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	for _, id := range torrentIDs {
+		if status, err := b.GetTorrentStatus(id); err == nil {
+			statuses[strings.ToLower(id)] = status
+		}
+	}
+	return statuses, nil
+}
+
+func (b *BlackholeClient) status(entry *blackholeEntry) (*TorrentStatus, error) {
+	files, err := b.files(entry)
+	if err != nil {
+		return nil, err
+	}
+	root, savePath, _ := b.layout(entry)
+
+	var onDisk, expected int64
+	present := len(files) > 0
+	for _, f := range files {
+		onDisk += int64(f.Progress / 100 * float64(f.Size))
+		expected += f.Size
+		if f.Progress < 100 {
+			present = false
+		}
+	}
+	// magnets only know what is on disk
+	if len(entry.Files) == 0 {
+		onDisk = expected
+	}
+
+	// clients that preallocate create full size files right away, writing into them still touches them
+	modified := lastModified(root)
+
+	now := time.Now()
+	if onDisk != entry.Size || !modified.Equal(entry.Modified) {
+		entry.Size, entry.Modified = onDisk, modified
+		entry.Changed = now
+		if err := b.save(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	status := &TorrentStatus{
+		ID:         entry.Hash,
+		Name:       entry.Name,
+		State:      "waiting",
+		Downloaded: onDisk,
+		TotalSize:  expected,
+		SavePath:   savePath,
+		IsComplete: present && now.Sub(entry.Changed) >= blackholeSettle && b.pickedUp(entry),
+	}
+	if expected > 0 {
+		status.Progress = float64(onDisk) / float64(expected) * 100
+		status.State = "downloading"
+	}
+	if status.IsComplete {
+		status.State = "completed"
+	}
+
+	return status, nil
+}
+
+// the torrent's files with how much of each is on disk. a magnet's files are whatever appeared so far
+func (b *BlackholeClient) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	entry, err := b.load(torrentID)
+	if err != nil {
+		return nil, err
+	}
+	return b.files(entry)
+}
+
+func (b *BlackholeClient) files(entry *blackholeEntry) ([]TorrentFile, error) {
+	root, savePath, isDir := b.layout(entry)
+
+	if len(entry.Files) == 0 {
+		return discoverFiles(root, savePath)
+	}
+
+	files := make([]TorrentFile, len(entry.Files))
+	for i, f := range entry.Files {
+		path := filepath.Join(root, filepath.FromSlash(f.Path))
+		rel := f.Path
+		if !isDir {
+			rel = entry.Name
+		}
+
+		files[i] = TorrentFile{Index: i, Path: rel, Size: f.Size}
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+		case info.Size() == f.Size:
+			files[i].Progress = 100
+		default:
+			// a file that isn't exactly the size the .torrent lists is not the finished one
+			files[i].Progress = min(float64(info.Size())/float64(f.Size)*100, 99.9)
+		}
+	}
+	return files, nil
+}
+
+// lists what a magnet download put on disk so far
+func discoverFiles(root, savePath string) ([]TorrentFile, error) {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []TorrentFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		progress := 100.0
+		if isPartial(path) {
+			progress = 0
+		}
+
+		rel, _ := filepath.Rel(savePath, path)
+		files = append(files, TorrentFile{Index: len(files), Path: filepath.ToSlash(rel), Size: info.Size(), Progress: progress})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func isPartial(path string) bool {
+	name := strings.ToLower(path)
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// the newest modification time of anything under root, zero if nothing is there
+func lastModified(root string) time.Time {
+	var newest time.Time
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest
+}
+
+// a magnet only says what belongs to the torrent once the client took it out of the watch folder,
+// until then whatever is in the completed folder may be half of it. .torrent entries know their files,
+// and some clients (rTorrent) leave them in the watch folder for good
+func (b *BlackholeClient) pickedUp(entry *blackholeEntry) bool {
+	if len(entry.Files) > 0 {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(b.config.WatchDir, strings.ToLower(entry.Hash)+".magnet"))
+	return os.IsNotExist(err)
+}
+
+func (b *BlackholeClient) ListTorrents() ([]TorrentStatus, error) {
+	entries, err := os.ReadDir(blackholeDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var statuses []TorrentStatus
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		entry, err := b.load(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if status, err := b.status(entry); err == nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
+
+// forgets the torrent and takes it out of the watch folder if the client didn't pick it up yet.
+// the data belongs to the other client, it is never deleted here
+func (b *BlackholeClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
+	hash := strings.ToLower(torrentID)
+	for _, name := range []string{hash + ".torrent", hash + ".magnet"} {
+		os.Remove(filepath.Join(b.config.WatchDir, name))
+	}
+
+	if err := os.Remove(blackholeEntryPath(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *BlackholeClient) PauseTorrent(torrentID string) error {
+	return fmt.Errorf("a blackhole can't pause torrents")
+}
+
+func (b *BlackholeClient) ResumeTorrent(torrentID string) error {
+	return fmt.Errorf("a blackhole can't resume torrents")
+}
+
+// the other client downloads every file, unwanted ones are just not imported
+func (b *BlackholeClient) SetFilePriorities(torrentID string, wanted []bool) error {
+	return nil
+}
+
+// the completed folder is local, its disk is what fills up
+func (b *BlackholeClient) GetClientInfo() (*ClientInfo, error) {
+	info := &ClientInfo{}
+	if free, total, err := shared.DiskUsage(b.config.CompletedDir); err == nil {
+		info.FreeSpace, info.TotalSpace = free, total
+	}
+	return info, nil
+}
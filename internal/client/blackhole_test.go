@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// a blackhole with its own watch, completed and config folders
+func newTestBlackhole(t *testing.T) *BlackholeClient {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	b := &BlackholeClient{}
+	b.config.WatchDir = t.TempDir()
+	b.config.CompletedDir = t.TempDir()
+	return b
+}
+
+// .torrent contents, files maps paths inside the torrent to sizes. a single "" path is a single file torrent
+func testTorrentFile(t *testing.T, name string, files map[string]int64) []byte {
+	info := metainfo.Info{Name: name, PieceLength: 16384}
+	if size, ok := files[""]; ok {
+		info.Length = size
+	} else {
+		for path, size := range files {
+			info.Files = append(info.Files, metainfo.FileInfo{Path: strings.Split(path, "/"), Length: size})
+		}
+	}
+
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bencode.Marshal(metainfo.MetaInfo{InfoBytes: infoBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeSized(t *testing.T, path string, size int) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pretends the files last changed longer ago than the settle time
+func settle(t *testing.T, b *BlackholeClient, hash string) {
+	entry, err := b.load(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Changed = entry.Changed.Add(-blackholeSettle)
+	if err := b.save(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertComplete(t *testing.T, b *BlackholeClient, hash string, want bool, when string) {
+	t.Helper()
+	status, err := b.GetTorrentStatus(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.IsComplete != want {
+		t.Errorf("%s: complete = %v, want %v (%.1f%%)", when, status.IsComplete, want, status.Progress)
+	}
+}
+
+func TestBlackholeTorrentCompletes(t *testing.T) {
+	b := newTestBlackhole(t)
+
+	hash, err := b.AddTorrentFile(context.Background(), testTorrentFile(t, "Show", map[string]int64{"ep1.mkv": 10, "ep1.srt": 5}), AddOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(b.config.WatchDir, hash+".torrent")); err != nil {
+		t.Fatalf("not in the watch folder: %v", err)
+	}
+
+	root := filepath.Join(b.config.CompletedDir, "Show")
+	assertComplete(t, b, hash, false, "nothing on disk")
+
+	writeSized(t, filepath.Join(root, "ep1.mkv"), 10)
+	writeSized(t, filepath.Join(root, "ep1.srt"), 3)
+	settle(t, b, hash)
+	assertComplete(t, b, hash, false, "a file too small")
+
+	writeSized(t, filepath.Join(root, "ep1.srt"), 6)
+	assertComplete(t, b, hash, false, "a file too big")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, false, "a file too big after settling")
+
+	writeSized(t, filepath.Join(root, "ep1.srt"), 5)
+	assertComplete(t, b, hash, false, "sizes right but just written")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, true, "settled")
+
+	// a preallocated file the client still writes into keeps its size
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "ep1.mkv"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	assertComplete(t, b, hash, false, "written to without changing size")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, true, "settled again")
+}
+
+func TestBlackholeSingleFile(t *testing.T) {
+	b := newTestBlackhole(t)
+
+	hash, err := b.AddTorrentFile(context.Background(), testTorrentFile(t, "Movie.mkv", map[string]int64{"": 8}), AddOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSized(t, filepath.Join(b.config.CompletedDir, "Movie.mkv"), 8)
+	assertComplete(t, b, hash, false, "just written")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, true, "settled")
+
+	status, err := b.GetTorrentStatus(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.SavePath != b.config.CompletedDir {
+		t.Errorf("save path = %q, want the completed folder", status.SavePath)
+	}
+
+	files, err := b.GetTorrentFiles(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "Movie.mkv" || files[0].Progress != 100 {
+		t.Errorf("files = %+v, want Movie.mkv done", files)
+	}
+}
+
+func TestBlackholeMagnetWaitsForPickup(t *testing.T) {
+	b := newTestBlackhole(t)
+
+	const magnet = "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=Show"
+	hash, err := b.AddTorrent(context.Background(), magnet, AddOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchFile := filepath.Join(b.config.WatchDir, hash+".magnet")
+	root := filepath.Join(b.config.CompletedDir, "Show")
+
+	writeSized(t, filepath.Join(root, "ep1.mkv"), 10)
+	settle(t, b, hash)
+	assertComplete(t, b, hash, false, "still in the watch folder")
+
+	if err := os.Remove(watchFile); err != nil {
+		t.Fatal(err)
+	}
+	writeSized(t, filepath.Join(root, "ep2.mkv.part"), 4)
+	assertComplete(t, b, hash, false, "picked up, more files appearing")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, false, "a partial file left")
+
+	if err := os.Rename(filepath.Join(root, "ep2.mkv.part"), filepath.Join(root, "ep2.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	assertComplete(t, b, hash, false, "partial file just finished")
+	settle(t, b, hash)
+	assertComplete(t, b, hash, true, "settled")
+
+	files, err := b.GetTorrentFiles(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != "ep1.mkv" || files[1].Path != "ep2.mkv" {
+		t.Errorf("files = %+v, want ep1.mkv and ep2.mkv", files)
+	}
+}
@@ -2,18 +2,44 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"opforjellyfin/internal/shared"
+	"os"
+	"sync"
+	"time"
 )
 
 type TorrentClient interface {
 	TestConnection() error
-	AddTorrent(ctx context.Context, torrentURL string, savePath string) (string, error)
+	AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) // .torrent URL or magnet URI, returns the infohash
+	AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error)   // uploads .torrent contents, for clients that can't reach the indexer
 	GetTorrentStatus(torrentID string) (*TorrentStatus, error)
+	GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) // in one request where the client can, by lower case hash. unknown ids are left out
 	RemoveTorrent(torrentID string, deleteFiles bool) error
 	PauseTorrent(torrentID string) error
 	ResumeTorrent(torrentID string) error
 	GetClientInfo() (*ClientInfo, error)
+	GetTorrentFiles(torrentID string) ([]TorrentFile, error)
+	SetFilePriorities(torrentID string, wanted []bool) error // by file index, false skips the file
+	ListTorrents() ([]TorrentStatus, error)                  // torrents in the opforjellyfin category or label, ID is the hash
+}
+
+// ErrTorrentNotFound is returned for a hash the client doesn't know
+var ErrTorrentNotFound = errors.New("torrent not found")
+
+// category or label torrents are added with, when none is configured
+const DefaultCategory = "OnePace"
+
+// AddOptions are applied to a torrent when it is added. zero values leave the client's defaults
+type AddOptions struct {
+	SavePath    string
+	Category    string  // qBittorrent category, Deluge/Transmission label
+	SeedRatio   float64 // stop seeding at this ratio
+	SeedMinutes int     // stop seeding after this long, qBittorrent only
+	Paused      bool
+	Sequential  bool // download pieces in order
 }
 
 type TorrentStatus struct {
@@ -25,6 +51,9 @@ type TorrentStatus struct {
 	TotalSize     int64
 	DownloadSpeed int64
 	UploadSpeed   int64
+	Uploaded      int64
+	Paused        bool
+	SeedingTime   time.Duration // time spent seeding, 0 when the client doesn't report it
 	Seeders       int
 	Peers         int
 	SavePath      string
@@ -32,17 +61,78 @@ type TorrentStatus struct {
 	IsComplete    bool
 }
 
+// a file inside a torrent, Path is relative to the save path and joined by '/'
+type TorrentFile struct {
+	Index    int
+	Path     string
+	Size     int64
+	Progress float64
+}
+
 type ClientInfo struct {
 	Version    string
 	FreeSpace  int64
 	TotalSpace int64
 }
 
+// clients by name, so sessions are reused across status checks.
+// a client whose settings changed is connected again
+var clients = struct {
+	sync.Mutex
+	byName map[string]*cachedClient
+}{byName: make(map[string]*cachedClient)}
+
+type cachedClient struct {
+	config string        // the settings it was made with, as JSON
+	ready  chan struct{} // closed once connected or failed
+	client TorrentClient
+	err    error
+}
+
+// how long a request to a client may take. a client that hangs fails like one that is down,
+// so routing moves on to the next one
+const requestTimeout = 30 * time.Second
+
+// NewClient returns the client for cfg, connecting only the first time it is asked for.
+// connecting happens outside the lock, a slow client only holds up callers asking for it.
+// failed connections aren't kept, the next call tries again
 func NewClient(cfg shared.TorrentClientConfig) (TorrentClient, error) {
 	if cfg.Type == "" || cfg.Type == "internal" {
 		return nil, nil
 	}
 
+	settings, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.ClientName()
+
+	clients.Lock()
+	cached, ok := clients.byName[name]
+	if ok && cached.config == string(settings) {
+		clients.Unlock()
+		<-cached.ready
+		return cached.client, cached.err
+	}
+
+	cached = &cachedClient{config: string(settings), ready: make(chan struct{})}
+	clients.byName[name] = cached
+	clients.Unlock()
+
+	cached.client, cached.err = newClient(cfg)
+	if cached.err != nil {
+		clients.Lock()
+		if clients.byName[name] == cached {
+			delete(clients.byName, name)
+		}
+		clients.Unlock()
+	}
+	close(cached.ready)
+
+	return cached.client, cached.err
+}
+
+func newClient(cfg shared.TorrentClientConfig) (TorrentClient, error) {
 	switch cfg.Type {
 	case "qbittorrent":
 		return NewQBittorrentClient(cfg)
@@ -50,11 +140,74 @@ func NewClient(cfg shared.TorrentClientConfig) (TorrentClient, error) {
 		return NewDelugeClient(cfg)
 	case "transmission":
 		return NewTransmissionClient(cfg)
+	case "aria2":
+		return NewAria2Client(cfg)
+	case "rtorrent":
+		return NewRTorrentClient(cfg)
+	case "blackhole":
+		return NewBlackholeClient(cfg)
 	default:
 		return nil, fmt.Errorf("unknown client type: %s", cfg.Type)
 	}
 }
 
+// Options returns the add options for a client and the seeding policy
+func Options(cfg shared.Config, tc shared.TorrentClientConfig) AddOptions {
+	ratio, minutes := cfg.Seeding.ClientLimits()
+	return AddOptions{
+		SavePath:    tc.SavePath,
+		Category:    Category(tc),
+		SeedRatio:   ratio,
+		SeedMinutes: minutes,
+		Paused:      tc.AddPaused,
+		Sequential:  tc.Sequential,
+	}
+}
+
+// Category returns the category or label opforjellyfin adds torrents with
+func Category(cfg shared.TorrentClientConfig) string {
+	if cfg.Category == "" {
+		return DefaultCategory
+	}
+	return cfg.Category
+}
+
 func IsInternalClient(cfg shared.TorrentClientConfig) bool {
 	return cfg.Type == "" || cfg.Type == "internal"
 }
+
+// HasLabels reports whether ListTorrents only returns torrents in the client's category.
+// aria2 has no labels, its list is every torrent the daemon has, opforjellyfin's or not
+func HasLabels(cfg shared.TorrentClientConfig) bool {
+	return cfg.Type != "aria2"
+}
+
+// IsBlackhole reports whether torrents go to another client through a watch folder, without peers or progress
+func IsBlackhole(cfg shared.TorrentClientConfig) bool {
+	return cfg.Type == "blackhole"
+}
+
+// deletes a torrent's file or folder for clients that can't do it themselves, returns the local path
+func deleteMappedData(mappings []shared.PathMapping, remotePath string) (string, error) {
+	root := shared.MapRemotePath(mappings, remotePath)
+	if err := os.RemoveAll(root); err != nil {
+		return root, fmt.Errorf("could not delete %s: %w", root, err)
+	}
+	return root, nil
+}
+
+// how often calls that are safe to repeat are tried before giving up
+const retryAttempts = 3
+
+var retryDelay = time.Second
+
+// withRetry runs call, and again after a failure if it is idempotent,
+// e.g reading a status while the client restarts
+func withRetry(idempotent bool, call func() error) error {
+	err := call()
+	for attempt := 1; idempotent && err != nil && attempt < retryAttempts; attempt++ {
+		time.Sleep(retryDelay * time.Duration(attempt))
+		err = call()
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"opforjellyfin/internal/shared"
+	"testing"
+	"time"
+)
+
+func TestNewClientDoesNotBlockOthers(t *testing.T) {
+	hit := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hit <- struct{}{}:
+		default:
+		}
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	go NewClient(shared.TorrentClientConfig{Name: "hanging", Type: "transmission", URL: hanging.URL})
+	<-hit
+
+	var calls []string
+	srv := fakeTransmission(t, &calls)
+	defer srv.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewClient(shared.TorrentClientConfig{Name: "fast", Type: "transmission", URL: srv.URL})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a hanging client blocked connecting to another one")
+	}
+
+	// the same client again is the cached one
+	a, _ := NewClient(shared.TorrentClientConfig{Name: "fast", Type: "transmission", URL: srv.URL})
+	b, _ := NewClient(shared.TorrentClientConfig{Name: "fast", Type: "transmission", URL: srv.URL})
+	if a != b {
+		t.Error("expected the cached client")
+	}
+}
@@ -3,16 +3,20 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/cookiejar"
+	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
+	"strings"
+	"time"
 )
 
 type DelugeClient struct {
-	config    shared.TorrentClientConfig
-	client    *http.Client
-	sessionID string
+	config shared.TorrentClientConfig
+	client *http.Client // the session cookie lives in its jar
 }
 
 type delugeRequest struct {
@@ -22,15 +26,51 @@ type delugeRequest struct {
 }
 
 type delugeResponse struct {
-	Result any `json:"result"`
-	Error  any `json:"error"`
-	ID     int `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *delugeError    `json:"error"`
+	ID     int             `json:"id"`
+}
+
+type delugeError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"` // 1 is not authenticated
+}
+
+func (e *delugeError) Error() string {
+	return fmt.Sprintf("deluge error %d: %s", e.Code, e.Message)
+}
+
+// the fields of core.get_torrent(s)_status we read, see delugeStatusFields
+type delugeTorrent struct {
+	Name          string  `json:"name"`
+	State         string  `json:"state"` // Downloading, Seeding, Paused, Checking, Queued, Error...
+	Message       string  `json:"message"`
+	Progress      float64 `json:"progress"` // 0-100
+	TotalDone     int64   `json:"total_done"`
+	TotalSize     int64   `json:"total_size"`
+	DownloadRate  float64 `json:"download_payload_rate"`
+	UploadRate    float64 `json:"upload_payload_rate"`
+	TotalUploaded int64   `json:"total_uploaded"`
+	SeedingTime   float64 `json:"seeding_time"` // seconds
+	SavePath      string  `json:"save_path"`
+	NumSeeds      int     `json:"num_seeds"`
+	NumPeers      int     `json:"num_peers"`
+
+	Files        []delugeFile `json:"files"`
+	FileProgress []float64    `json:"file_progress"` // 0-1, same order as files
+}
+
+type delugeFile struct {
+	Index int    `json:"index"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
 }
 
 func NewDelugeClient(cfg shared.TorrentClientConfig) (*DelugeClient, error) {
+	jar, _ := cookiejar.New(nil)
 	client := &DelugeClient{
 		config: cfg,
-		client: &http.Client{},
+		client: &http.Client{Jar: jar, Timeout: requestTimeout},
 	}
 
 	if err := client.login(); err != nil {
@@ -41,25 +81,65 @@ func NewDelugeClient(cfg shared.TorrentClientConfig) (*DelugeClient, error) {
 }
 
 func (d *DelugeClient) login() error {
-	req := delugeRequest{
-		Method: "auth.login",
-		Params: []any{d.config.Password},
-		ID:     1,
+	var ok bool
+	if err := d.call("auth.login", &ok, d.config.Password); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("login failed")
+	}
+
+	return nil
+}
+
+// call runs method and decodes its result into result, unless that is nil. RPC errors are returned as *delugeError
+func (d *DelugeClient) call(method string, result any, params ...any) error {
+	if params == nil {
+		params = []any{}
 	}
 
 	var resp delugeResponse
-	if err := d.makeRequest(req, &resp); err != nil {
+	if err := d.makeRequest(delugeRequest{Method: method, Params: params, ID: 1}, &resp); err != nil {
 		return err
 	}
-
-	if result, ok := resp.Result.(bool); !ok || !result {
-		return fmt.Errorf("login failed")
+	if resp.Error != nil {
+		return fmt.Errorf("%s: %w", method, resp.Error)
 	}
 
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
 	return nil
 }
 
+// makeRequest logs in again when the web UI forgot the session, e.g after a restart,
+// and retries reads when the connection fails
 func (d *DelugeClient) makeRequest(req delugeRequest, resp *delugeResponse) error {
+	idempotent := strings.HasPrefix(req.Method, "core.get_") || req.Method == "daemon.info"
+	send := func() error {
+		return withRetry(idempotent, func() error {
+			*resp = delugeResponse{}
+			return d.send(req, resp)
+		})
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+	if req.Method == "auth.login" || resp.Error == nil || resp.Error.Code != 1 {
+		return nil
+	}
+
+	if err := d.login(); err != nil {
+		return fmt.Errorf("session expired and login failed: %w", err)
+	}
+	return send()
+}
+
+func (d *DelugeClient) send(req delugeRequest, resp *delugeResponse) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return err
@@ -81,114 +161,229 @@ func (d *DelugeClient) makeRequest(req delugeRequest, resp *delugeResponse) erro
 }
 
 func (d *DelugeClient) TestConnection() error {
-	req := delugeRequest{
-		Method: "daemon.info",
-		Params: []any{},
-		ID:     1,
+	return d.call("daemon.info", nil)
+}
+
+func (d *DelugeClient) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	method := "core.add_torrent_url"
+	if shared.IsMagnet(torrentURL) {
+		method = "core.add_torrent_magnet"
 	}
 
-	var resp delugeResponse
-	return d.makeRequest(req, &resp)
+	// null when deluge didn't add it, e.g the torrent is already there
+	var hash *string
+	if err := d.call(method, &hash, torrentURL, delugeAddOptions(opts)); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+	if hash == nil || *hash == "" {
+		return "", fmt.Errorf("failed to add torrent")
+	}
+
+	d.setLabel(*hash, opts.Category)
+	return *hash, nil
+}
+
+func (d *DelugeClient) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	hash, err := InfoHashOf(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.call("core.add_torrent_file", nil, hash+".torrent", base64.StdEncoding.EncodeToString(data), delugeAddOptions(opts)); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+
+	d.setLabel(hash, opts.Category)
+	return hash, nil
 }
 
-func (d *DelugeClient) AddTorrent(ctx context.Context, torrentURL string, savePath string) (string, error) {
-	req := delugeRequest{
-		Method: "core.add_torrent_url",
-		Params: []any{torrentURL, map[string]any{"download_location": savePath}},
-		ID:     1,
+// torrent options for core.add_torrent_*, deluge has no per torrent seed time
+func delugeAddOptions(opts AddOptions) map[string]any {
+	options := map[string]any{"add_paused": opts.Paused}
+	if opts.SavePath != "" {
+		options["download_location"] = opts.SavePath
+	}
+	if opts.SeedRatio > 0 {
+		options["stop_at_ratio"] = true
+		options["stop_ratio"] = opts.SeedRatio
+	}
+	if opts.Sequential {
+		options["sequential_download"] = true
 	}
+	return options
+}
 
-	var resp delugeResponse
-	if err := d.makeRequest(req, &resp); err != nil {
-		return "", err
+// labels the torrent so ListTorrents finds it. needs the label plugin, without it torrents just stay unlabeled
+func (d *DelugeClient) setLabel(hash, category string) {
+	if category == "" {
+		return
 	}
+	// deluge only accepts lowercase labels
+	label := strings.ToLower(category)
 
-	if hash, ok := resp.Result.(string); ok {
-		return hash, nil
+	// fails if the label exists, which is fine
+	d.call("label.add", nil, label)
+
+	if err := d.call("label.set_torrent", nil, hash, label); err != nil {
+		logger.Log(false, "Could not label %s in Deluge, is the label plugin enabled? %v", hash, err)
 	}
+}
 
-	return "", fmt.Errorf("failed to add torrent")
+var delugeStatusFields = []string{"name", "state", "message", "progress", "total_done", "total_size", "download_payload_rate", "upload_payload_rate", "save_path", "num_seeds", "num_peers", "total_uploaded", "seeding_time"}
+
+// core.get_torrent_status, an unknown hash gives an empty status
+func (d *DelugeClient) torrent(torrentID string, fields []string) (*delugeTorrent, error) {
+	var t delugeTorrent
+	if err := d.call("core.get_torrent_status", &t, torrentID, fields); err != nil {
+		return nil, err
+	}
+	if t.Name == "" && t.State == "" && t.Files == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTorrentNotFound, torrentID)
+	}
+	return &t, nil
 }
 
 func (d *DelugeClient) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
-	req := delugeRequest{
-		Method: "core.get_torrent_status",
-		Params: []any{torrentID, []string{"name", "state", "progress", "total_done", "total_size", "download_payload_rate", "upload_payload_rate", "save_path"}},
-		ID:     1,
+	t, err := d.torrent(torrentID, delugeStatusFields)
+	if err != nil {
+		return nil, err
 	}
+	return t.status(torrentID), nil
+}
 
-	var resp delugeResponse
-	if err := d.makeRequest(req, &resp); err != nil {
+func (d *DelugeClient) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	if len(torrentIDs) == 0 {
+		return statuses, nil
+	}
+
+	torrents, err := d.torrents(map[string]any{"id": torrentIDs})
+	if err != nil {
 		return nil, err
 	}
 
-	statusMap, ok := resp.Result.(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("invalid status response")
+	for hash, t := range torrents {
+		statuses[strings.ToLower(hash)] = t.status(hash)
 	}
+	return statuses, nil
+}
 
-	progress, _ := statusMap["progress"].(float64)
+func (d *DelugeClient) ListTorrents() ([]TorrentStatus, error) {
+	torrents, err := d.torrents(map[string]any{"label": strings.ToLower(Category(d.config))})
+	if err != nil {
+		return nil, err
+	}
 
-	return &TorrentStatus{
-		ID:            torrentID,
-		Name:          statusMap["name"].(string),
-		State:         statusMap["state"].(string),
-		Progress:      progress,
-		Downloaded:    int64(statusMap["total_done"].(float64)),
-		TotalSize:     int64(statusMap["total_size"].(float64)),
-		DownloadSpeed: int64(statusMap["download_payload_rate"].(float64)),
-		UploadSpeed:   int64(statusMap["upload_payload_rate"].(float64)),
-		SavePath:      statusMap["save_path"].(string),
-		IsComplete:    progress >= 100,
-	}, nil
+	statuses := make([]TorrentStatus, 0, len(torrents))
+	for hash, t := range torrents {
+		statuses = append(statuses, *t.status(hash))
+	}
+	return statuses, nil
 }
 
-func (d *DelugeClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
-	req := delugeRequest{
-		Method: "core.remove_torrent",
-		Params: []any{torrentID, deleteFiles},
-		ID:     1,
+// core.get_torrents_status for the torrents matching filter, by hash
+func (d *DelugeClient) torrents(filter map[string]any) (map[string]*delugeTorrent, error) {
+	var torrents map[string]*delugeTorrent
+	if err := d.call("core.get_torrents_status", &torrents, filter, delugeStatusFields); err != nil {
+		return nil, err
 	}
+	return torrents, nil
+}
 
-	var resp delugeResponse
-	return d.makeRequest(req, &resp)
+func (t *delugeTorrent) status(hash string) *TorrentStatus {
+	status := &TorrentStatus{
+		ID:            hash,
+		Name:          t.Name,
+		State:         t.State,
+		Progress:      t.Progress,
+		Downloaded:    t.TotalDone,
+		TotalSize:     t.TotalSize,
+		DownloadSpeed: int64(t.DownloadRate),
+		UploadSpeed:   int64(t.UploadRate),
+		Uploaded:      t.TotalUploaded,
+		Paused:        t.State == "Paused",
+		SeedingTime:   time.Duration(t.SeedingTime) * time.Second,
+		Seeders:       t.NumSeeds,
+		Peers:         t.NumPeers,
+		SavePath:      t.SavePath,
+		IsComplete:    t.Progress >= 100,
+	}
+	if t.State == "Error" {
+		status.Error = t.Message
+	}
+	return status
+}
+
+func (d *DelugeClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
+	return d.call("core.remove_torrent", nil, torrentID, deleteFiles)
 }
 
 func (d *DelugeClient) PauseTorrent(torrentID string) error {
-	req := delugeRequest{
-		Method: "core.pause_torrent",
-		Params: []any{[]string{torrentID}},
-		ID:     1,
+	return d.call("core.pause_torrent", nil, []string{torrentID})
+}
+
+func (d *DelugeClient) ResumeTorrent(torrentID string) error {
+	return d.call("core.resume_torrent", nil, []string{torrentID})
+}
+
+func (d *DelugeClient) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	t, err := d.torrent(torrentID, []string{"files", "file_progress"})
+	if err != nil {
+		return nil, err
 	}
 
-	var resp delugeResponse
-	return d.makeRequest(req, &resp)
+	result := make([]TorrentFile, 0, len(t.Files))
+	for i, f := range t.Files {
+		tf := TorrentFile{
+			Index: f.Index,
+			Path:  f.Path,
+			Size:  f.Size,
+		}
+		if i < len(t.FileProgress) {
+			tf.Progress = t.FileProgress[i] * 100
+		}
+
+		result = append(result, tf)
+	}
+
+	return result, nil
 }
 
-func (d *DelugeClient) ResumeTorrent(torrentID string) error {
-	req := delugeRequest{
-		Method: "core.resume_torrent",
-		Params: []any{[]string{torrentID}},
-		ID:     1,
+func (d *DelugeClient) SetFilePriorities(torrentID string, wanted []bool) error {
+	// 0 skips the file, 4 is normal priority
+	priorities := make([]int, len(wanted))
+	for i, w := range wanted {
+		if w {
+			priorities[i] = 4
+		}
 	}
 
-	var resp delugeResponse
-	return d.makeRequest(req, &resp)
+	if err := d.call("core.set_torrent_options", nil, []string{torrentID}, map[string]any{"file_priorities": priorities}); err != nil {
+		return fmt.Errorf("failed to set file priorities: %w", err)
+	}
+
+	return nil
 }
 
 func (d *DelugeClient) GetClientInfo() (*ClientInfo, error) {
-	req := delugeRequest{
-		Method: "daemon.info",
-		Params: []any{},
-		ID:     1,
+	// the version of the web UI, the daemon may differ
+	var version string
+	if err := d.call("daemon.info", &version); err != nil {
+		return nil, err
 	}
 
-	var resp delugeResponse
-	if err := d.makeRequest(req, &resp); err != nil {
-		return nil, err
+	info := &ClientInfo{
+		Version: version,
+	}
+
+	// free space on the save path, the daemon's download location if none is set
+	var params []any
+	if d.config.SavePath != "" {
+		params = append(params, d.config.SavePath)
+	}
+	if err := d.call("core.get_free_space", &info.FreeSpace, params...); err != nil {
+		logger.Log(false, "Deluge: could not get free space: %v", err)
 	}
 
-	return &ClientInfo{
-		Version: "Deluge",
-	}, nil
+	return info, nil
 }
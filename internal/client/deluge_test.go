@@ -0,0 +1,133 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// replies with testdata/<dir>/<name>.json, fixture names the recorded response for a request body
+func fakeRecorded(t *testing.T, dir string, fixture func(w http.ResponseWriter, r *http.Request, body []byte) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read request: %v", err)
+			return
+		}
+
+		name := fixture(w, r, body)
+		if name == "" {
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", dir, name+".json"))
+		if err != nil {
+			t.Errorf("no recorded response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+// a Deluge web UI. clearing session forgets the login like a restart does
+func fakeDeluge(t *testing.T, calls *[]string, session *string) *httptest.Server {
+	return fakeRecorded(t, "deluge", func(w http.ResponseWriter, r *http.Request, body []byte) string {
+		var req delugeRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return ""
+		}
+		*calls = append(*calls, req.Method)
+
+		if req.Method == "auth.login" {
+			*session = "s" + string(rune('0'+len(*calls)))
+			http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: *session, Path: "/"})
+			return "auth.login"
+		}
+		if cookie, err := r.Cookie("_session_id"); err != nil || cookie.Value != *session {
+			return "not_authenticated"
+		}
+
+		if req.Method == "core.get_torrent_status" {
+			fields, _ := req.Params[1].([]any)
+			switch {
+			case req.Params[0] != testHash:
+				return "core.get_torrent_status.unknown"
+			case slices.Contains(fields, any("files")):
+				return "core.get_torrent_status.files"
+			}
+		}
+		return req.Method
+	})
+}
+
+func TestDelugeClient(t *testing.T) {
+	var calls []string
+	var session string
+	srv := fakeDeluge(t, &calls, &session)
+	defer srv.Close()
+
+	c, err := NewDelugeClient(shared.TorrentClientConfig{URL: srv.URL, Password: "deluge"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.GetTorrentStatus(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TorrentStatus{
+		ID: testHash, Name: "[One Pace][1-7] Romance Dawn [1080p]", State: "Error", Progress: 42.5,
+		Downloaded: 425000000, TotalSize: 1000000000, Uploaded: 12000000, Seeders: 3, Peers: 7,
+		SavePath: "/downloads", Error: "Error: No space left on device",
+	}
+	if *status != want {
+		t.Errorf("status = %+v, want %+v", *status, want)
+	}
+
+	if _, err := c.GetTorrentStatus("0000000000000000000000000000000000000000"); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("expected ErrTorrentNotFound for an unknown hash, got %v", err)
+	}
+
+	// the session is gone after a restart, the client logs in again and retries
+	session = "restarted"
+	calls = nil
+	statuses, err := c.GetTorrentStatuses([]string{testHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(calls, []string{"core.get_torrents_status", "auth.login", "core.get_torrents_status"}) {
+		t.Errorf("unexpected calls after the session expired: %v", calls)
+	}
+	seeding := statuses[testHash]
+	if seeding == nil || !seeding.IsComplete || seeding.State != "Seeding" || seeding.SeedingTime != time.Hour || seeding.Error != "" {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	files, err := c.GetTorrentFiles(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Progress != 100 || files[1].Progress != 0 || files[1].Index != 1 || files[1].Size != 400000000 {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	var rpcErr *delugeError
+	if err := c.SetFilePriorities(testHash, []bool{true, false}); !errors.As(err, &rpcErr) || rpcErr.Code != 4 {
+		t.Errorf("expected the RPC error, got %v", err)
+	}
+
+	info, err := c.GetClientInfo()
+	if err != nil || info.Version != "2.1.1" || info.FreeSpace != 50<<30 {
+		t.Errorf("GetClientInfo = %+v, %v", info, err)
+	}
+}
@@ -11,14 +11,16 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"opforjellyfin/internal/shared"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type QBittorrentClient struct {
-	config shared.TorrentClientConfig
-	client *http.Client
-	cookie string
+	config  shared.TorrentClientConfig
+	client  *http.Client
+	loginMu sync.Mutex
 }
 
 func NewQBittorrentClient(cfg shared.TorrentClientConfig) (*QBittorrentClient, error) {
@@ -26,7 +28,8 @@ func NewQBittorrentClient(cfg shared.TorrentClientConfig) (*QBittorrentClient, e
 	client := &QBittorrentClient{
 		config: cfg,
 		client: &http.Client{
-			Jar: jar,
+			Jar:     jar,
+			Timeout: requestTimeout,
 		},
 	}
 
@@ -38,6 +41,9 @@ func NewQBittorrentClient(cfg shared.TorrentClientConfig) (*QBittorrentClient, e
 }
 
 func (q *QBittorrentClient) login() error {
+	q.loginMu.Lock()
+	defer q.loginMu.Unlock()
+
 	data := url.Values{}
 	data.Set("username", q.config.Username)
 	data.Set("password", q.config.Password)
@@ -59,8 +65,55 @@ func (q *QBittorrentClient) login() error {
 	return nil
 }
 
+// get reads from the API, retried when the connection fails
+func (q *QBittorrentClient) get(path string) (*http.Response, error) {
+	return q.do(true, func() (*http.Request, error) {
+		return http.NewRequest("GET", q.config.URL+path, nil)
+	})
+}
+
+// post sends a form, only once since it changes something
+func (q *QBittorrentClient) post(path string, data url.Values) (*http.Response, error) {
+	return q.do(false, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", q.config.URL+path, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+}
+
+// do sends the request built by newReq. qBittorrent answers 403 once the session cookie expired,
+// e.g after a restart, then we log in again and send it once more
+func (q *QBittorrentClient) do(idempotent bool, newReq func() (*http.Request, error)) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		var resp *http.Response
+		err := withRetry(idempotent, func() error {
+			req, err := newReq()
+			if err != nil {
+				return err
+			}
+			resp, err = q.client.Do(req)
+			return err
+		})
+		return resp, err
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := q.login(); err != nil {
+		return nil, fmt.Errorf("session expired and login failed: %w", err)
+	}
+	return send()
+}
+
 func (q *QBittorrentClient) TestConnection() error {
-	resp, err := q.client.Get(q.config.URL + "/api/v2/app/version")
+	resp, err := q.get("/api/v2/app/version")
 	if err != nil {
 		return err
 	}
@@ -73,53 +126,112 @@ func (q *QBittorrentClient) TestConnection() error {
 	return nil
 }
 
-func (q *QBittorrentClient) AddTorrent(ctx context.Context, torrentURL string, savePath string) (string, error) {
-	existingTorrents, err := q.getExistingHashes()
+func (q *QBittorrentClient) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	// a magnet names its hash, no need to diff the torrent list
+	magnetHash := shared.InfoHashFromMagnet(torrentURL)
+
+	var existingTorrents map[string]bool
+	if magnetHash == "" {
+		var err error
+		existingTorrents, err = q.getExistingHashes()
+		if err != nil {
+			return "", fmt.Errorf("failed to get existing torrents: %w", err)
+		}
+	}
+
+	err := q.postAdd(ctx, opts, func(writer *multipart.Writer) error {
+		return writer.WriteField("urls", torrentURL)
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to get existing torrents: %w", err)
+		return "", err
 	}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	if magnetHash != "" {
+		return magnetHash, nil
+	}
 
-	writer.WriteField("urls", torrentURL)
-	if savePath != "" {
-		writer.WriteField("savepath", savePath)
+	hash, err := q.getNewlyAddedHash(existingTorrents)
+	if err != nil {
+		return "", fmt.Errorf("torrent added but failed to get hash: %w", err)
 	}
-	writer.WriteField("category", "OnePace")
-	// Set seed ratio limit to 0.6 (remove after reaching 60% ratio)
-	writer.WriteField("ratioLimit", "0.6")
-	writer.WriteField("seedingTimeLimit", "-1") // No time limit, only ratio
 
-	writer.Close()
+	return hash, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", q.config.URL+"/api/v2/torrents/add", body)
+func (q *QBittorrentClient) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	hash, err := InfoHashOf(data)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := q.client.Do(req)
+	err = q.postAdd(ctx, opts, func(writer *multipart.Writer) error {
+		part, err := writer.CreateFormFile("torrents", hash+".torrent")
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(data)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to add torrent: %s", string(bodyBytes))
+	return hash, nil
+}
+
+// posts to torrents/add, addSource writes the urls or torrents field
+func (q *QBittorrentClient) postAdd(ctx context.Context, opts AddOptions, addSource func(*multipart.Writer) error) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	if err := addSource(writer); err != nil {
+		return err
+	}
+	if opts.SavePath != "" {
+		writer.WriteField("savepath", opts.SavePath)
+	}
+	if opts.Category != "" {
+		writer.WriteField("category", opts.Category)
+	}
+	if opts.SeedRatio > 0 {
+		writer.WriteField("ratioLimit", strconv.FormatFloat(opts.SeedRatio, 'f', 2, 64))
+	}
+	if opts.SeedMinutes > 0 {
+		writer.WriteField("seedingTimeLimit", strconv.Itoa(opts.SeedMinutes))
+	}
+	if opts.Paused {
+		writer.WriteField("paused", "true")
+		writer.WriteField("stopped", "true") // qBittorrent 5 renamed it
+	}
+	if opts.Sequential {
+		writer.WriteField("sequentialDownload", "true")
 	}
 
-	hash, err := q.getNewlyAddedHash(existingTorrents)
+	writer.Close()
+
+	resp, err := q.do(false, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", q.config.URL+"/api/v2/torrents/add", bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("torrent added but failed to get hash: %w", err)
+		return err
 	}
+	defer resp.Body.Close()
 
-	return hash, nil
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to add torrent: %s", string(bodyBytes))
+	}
+
+	return nil
 }
 
 func (q *QBittorrentClient) getExistingHashes() (map[string]bool, error) {
-	resp, err := q.client.Get(q.config.URL + "/api/v2/torrents/info?category=OnePace")
+	resp, err := q.get("/api/v2/torrents/info?category=" + url.QueryEscape(Category(q.config)))
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +256,7 @@ func (q *QBittorrentClient) getNewlyAddedHash(existingHashes map[string]bool) (s
 	for i := 0; i < 10; i++ {
 		time.Sleep(500 * time.Millisecond)
 
-		resp, err := q.client.Get(q.config.URL + "/api/v2/torrents/info?category=OnePace")
+		resp, err := q.get("/api/v2/torrents/info?category=" + url.QueryEscape(Category(q.config)))
 		if err != nil {
 			return "", err
 		}
@@ -174,7 +286,7 @@ func (q *QBittorrentClient) getNewlyAddedHash(existingHashes map[string]bool) (s
 }
 
 func (q *QBittorrentClient) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
-	resp, err := q.client.Get(q.config.URL + "/api/v2/torrents/info?hashes=" + torrentID)
+	resp, err := q.get("/api/v2/torrents/info?hashes=" + torrentID)
 	if err != nil {
 		return nil, err
 	}
@@ -186,10 +298,56 @@ func (q *QBittorrentClient) GetTorrentStatus(torrentID string) (*TorrentStatus,
 	}
 
 	if len(torrents) == 0 {
-		return nil, fmt.Errorf("torrent not found: %s", torrentID)
+		return nil, fmt.Errorf("%w: %s", ErrTorrentNotFound, torrentID)
+	}
+
+	return qbitStatus(torrents[0]), nil
+}
+
+func (q *QBittorrentClient) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	if len(torrentIDs) == 0 {
+		return statuses, nil
+	}
+
+	resp, err := q.get("/api/v2/torrents/info?hashes=" + url.QueryEscape(strings.Join(torrentIDs, "|")))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var torrents []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
+		return nil, err
+	}
+
+	for _, t := range torrents {
+		status := qbitStatus(t)
+		statuses[strings.ToLower(status.ID)] = status
 	}
+	return statuses, nil
+}
 
-	t := torrents[0]
+func (q *QBittorrentClient) ListTorrents() ([]TorrentStatus, error) {
+	resp, err := q.get("/api/v2/torrents/info?category=" + url.QueryEscape(Category(q.config)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var torrents []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]TorrentStatus, 0, len(torrents))
+	for _, t := range torrents {
+		statuses = append(statuses, *qbitStatus(t))
+	}
+	return statuses, nil
+}
+
+func qbitStatus(t map[string]any) *TorrentStatus {
 	hash, _ := t["hash"].(string)
 	name, _ := t["name"].(string)
 	state, _ := t["state"].(string)
@@ -198,7 +356,11 @@ func (q *QBittorrentClient) GetTorrentStatus(torrentID string) (*TorrentStatus,
 	totalSize, _ := t["size"].(float64)
 	dlspeed, _ := t["dlspeed"].(float64)
 	upspeed, _ := t["upspeed"].(float64)
+	uploaded, _ := t["uploaded"].(float64)
+	seedingTime, _ := t["seeding_time"].(float64)
 	savePath, _ := t["save_path"].(string)
+	numSeeds, _ := t["num_seeds"].(float64)
+	numLeechs, _ := t["num_leechs"].(float64)
 
 	return &TorrentStatus{
 		ID:            hash,
@@ -209,9 +371,14 @@ func (q *QBittorrentClient) GetTorrentStatus(torrentID string) (*TorrentStatus,
 		TotalSize:     int64(totalSize),
 		DownloadSpeed: int64(dlspeed),
 		UploadSpeed:   int64(upspeed),
+		Uploaded:      int64(uploaded),
+		Paused:        strings.HasPrefix(state, "paused") || strings.HasPrefix(state, "stopped"),
+		SeedingTime:   time.Duration(seedingTime) * time.Second,
+		Seeders:       int(numSeeds),
+		Peers:         int(numLeechs),
 		SavePath:      savePath,
 		IsComplete:    progress >= 1.0,
-	}, nil
+	}
 }
 
 func (q *QBittorrentClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
@@ -219,11 +386,7 @@ func (q *QBittorrentClient) RemoveTorrent(torrentID string, deleteFiles bool) er
 	data.Set("hashes", torrentID)
 	data.Set("deleteFiles", fmt.Sprintf("%t", deleteFiles))
 
-	resp, err := q.client.Post(
-		q.config.URL+"/api/v2/torrents/delete",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()),
-	)
+	resp, err := q.post("/api/v2/torrents/delete", data)
 	if err != nil {
 		return err
 	}
@@ -236,11 +399,7 @@ func (q *QBittorrentClient) PauseTorrent(torrentID string) error {
 	data := url.Values{}
 	data.Set("hashes", torrentID)
 
-	resp, err := q.client.Post(
-		q.config.URL+"/api/v2/torrents/pause",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()),
-	)
+	resp, err := q.post("/api/v2/torrents/pause", data)
 	if err != nil {
 		return err
 	}
@@ -253,11 +412,7 @@ func (q *QBittorrentClient) ResumeTorrent(torrentID string) error {
 	data := url.Values{}
 	data.Set("hashes", torrentID)
 
-	resp, err := q.client.Post(
-		q.config.URL+"/api/v2/torrents/resume",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()),
-	)
+	resp, err := q.post("/api/v2/torrents/resume", data)
 	if err != nil {
 		return err
 	}
@@ -266,8 +421,82 @@ func (q *QBittorrentClient) ResumeTorrent(torrentID string) error {
 	return nil
 }
 
+func (q *QBittorrentClient) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	resp, err := q.get("/api/v2/torrents/files?hash=" + torrentID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get files with status: %d", resp.StatusCode)
+	}
+
+	var files []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return nil, err
+	}
+
+	result := make([]TorrentFile, 0, len(files))
+	for i, f := range files {
+		name, _ := f["name"].(string)
+		size, _ := f["size"].(float64)
+		progress, _ := f["progress"].(float64)
+
+		// older versions don't send the index, the list is in file order
+		index := i
+		if idx, ok := f["index"].(float64); ok {
+			index = int(idx)
+		}
+
+		result = append(result, TorrentFile{
+			Index:    index,
+			Path:     name,
+			Size:     int64(size),
+			Progress: progress * 100,
+		})
+	}
+
+	return result, nil
+}
+
+func (q *QBittorrentClient) SetFilePriorities(torrentID string, wanted []bool) error {
+	var skip, keep []string
+	for i, w := range wanted {
+		if w {
+			keep = append(keep, strconv.Itoa(i))
+		} else {
+			skip = append(skip, strconv.Itoa(i))
+		}
+	}
+
+	// priority 0 is do not download, 1 is normal
+	for priority, ids := range map[string][]string{"0": skip, "1": keep} {
+		if len(ids) == 0 {
+			continue
+		}
+
+		data := url.Values{}
+		data.Set("hash", torrentID)
+		data.Set("id", strings.Join(ids, "|"))
+		data.Set("priority", priority)
+
+		resp, err := q.post("/api/v2/torrents/filePrio", data)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to set file priority with status: %d", resp.StatusCode)
+		}
+	}
+
+	return nil
+}
+
 func (q *QBittorrentClient) GetClientInfo() (*ClientInfo, error) {
-	resp, err := q.client.Get(q.config.URL + "/api/v2/app/version")
+	resp, err := q.get("/api/v2/app/version")
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +507,22 @@ func (q *QBittorrentClient) GetClientInfo() (*ClientInfo, error) {
 		return nil, err
 	}
 
-	return &ClientInfo{
+	info := &ClientInfo{
 		Version: strings.TrimSpace(string(versionBytes)),
-	}, nil
+	}
+
+	// free space on the default save path
+	if resp, err := q.get("/api/v2/sync/maindata"); err == nil {
+		defer resp.Body.Close()
+		var data struct {
+			ServerState struct {
+				FreeSpaceOnDisk int64 `json:"free_space_on_disk"`
+			} `json:"server_state"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&data) == nil {
+			info.FreeSpace = data.ServerState.FreeSpaceOnDisk
+		}
+	}
+
+	return info, nil
 }
@@ -0,0 +1,434 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"opforjellyfin/internal/shared"
+	"path/filepath"
+	"strings"
+)
+
+// rTorrent over XML-RPC, through an HTTP gateway to its SCGI socket, e.g nginx or ruTorrent's /RPC2.
+// the label lives in d.custom1 like ruTorrent does it. rTorrent has no per torrent seed limits,
+// the worker removes torrents once the seeding policy is reached.
+type RTorrentClient struct {
+	config shared.TorrentClientConfig
+	client *http.Client
+}
+
+// d.* commands read for a status, in this order
+var rtorrentStatusFields = []string{
+	"d.hash", "d.name", "d.state", "d.complete", "d.completed_bytes", "d.size_bytes",
+	"d.down.rate", "d.up.rate", "d.up.total", "d.peers_complete", "d.peers_accounted",
+	"d.directory", "d.message", "d.custom1", "d.is_multi_file",
+}
+
+// methods that only read, retried when the connection fails
+var rtorrentReads = map[string]bool{
+	"system.client_version": true, "system.multicall": true, "system.free_diskspace": true,
+	"d.multicall2": true, "f.multicall": true, "d.name": true, "d.directory": true, "d.is_multi_file": true,
+	"directory.default": true,
+}
+
+func NewRTorrentClient(cfg shared.TorrentClientConfig) (*RTorrentClient, error) {
+	client := &RTorrentClient{
+		config: cfg,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+
+	if err := client.TestConnection(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	return client, nil
+}
+
+func (r *RTorrentClient) call(method string, params ...any) (any, error) {
+	body, err := xmlrpcEncode(method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	err = withRetry(rtorrentReads[method], func() error {
+		req, err := http.NewRequest("POST", r.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/xml")
+		if r.config.Username != "" {
+			req.SetBasicAuth(r.config.Username, r.config.Password)
+		}
+
+		resp, err = r.client.Do(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s failed with status: %d", method, resp.StatusCode)
+	}
+
+	result, err := xmlrpcDecode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	return result, nil
+}
+
+func (r *RTorrentClient) TestConnection() error {
+	_, err := r.call("system.client_version")
+	return err
+}
+
+// rTorrent wants hashes in upper case
+func rtorrentHash(hash string) string {
+	return strings.ToUpper(hash)
+}
+
+func (r *RTorrentClient) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	hash := shared.InfoHashFromMagnet(torrentURL)
+	if hash == "" {
+		// rTorrent fetches URLs in the background, the hash is only known from the file
+		data, err := FetchTorrentFile(ctx, torrentURL)
+		if err != nil {
+			return "", err
+		}
+		return r.AddTorrentFile(ctx, data, opts)
+	}
+
+	method := "load.start_verbose"
+	if opts.Paused {
+		method = "load.normal_verbose"
+	}
+
+	params := append([]any{"", torrentURL}, rtorrentLoadCommands(opts)...)
+	if _, err := r.call(method, params...); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+
+	return hash, nil
+}
+
+func (r *RTorrentClient) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	hash, err := InfoHashOf(data)
+	if err != nil {
+		return "", err
+	}
+
+	method := "load.raw_start_verbose"
+	if opts.Paused {
+		method = "load.raw_verbose"
+	}
+
+	params := append([]any{"", data}, rtorrentLoadCommands(opts)...)
+	if _, err := r.call(method, params...); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+
+	return hash, nil
+}
+
+// commands run on the new download. rTorrent can't download in order
+func rtorrentLoadCommands(opts AddOptions) []any {
+	var commands []any
+	if opts.SavePath != "" {
+		commands = append(commands, "d.directory.set="+opts.SavePath)
+	}
+	if opts.Category != "" {
+		// ruTorrent url-encodes its labels
+		commands = append(commands, "d.custom1.set="+url.PathEscape(opts.Category))
+	}
+	return commands
+}
+
+func (r *RTorrentClient) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
+	statuses, err := r.GetTorrentStatuses([]string{torrentID})
+	if err != nil {
+		return nil, err
+	}
+
+	status, ok := statuses[strings.ToLower(torrentID)]
+	if !ok {
+		return nil, ErrTorrentNotFound
+	}
+	return status, nil
+}
+
+// every field of every torrent in one system.multicall
+func (r *RTorrentClient) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	if len(torrentIDs) == 0 {
+		return statuses, nil
+	}
+
+	calls := make([]any, 0, len(torrentIDs)*len(rtorrentStatusFields))
+	for _, id := range torrentIDs {
+		for _, field := range rtorrentStatusFields {
+			calls = append(calls, map[string]any{"methodName": field, "params": []any{rtorrentHash(id)}})
+		}
+	}
+
+	result, err := r.call("system.multicall", calls)
+	if err != nil {
+		return nil, err
+	}
+
+	// every call answers [value], or a fault struct if the hash is unknown
+	results, _ := result.([]any)
+	for i := 0; i+len(rtorrentStatusFields) <= len(results); i += len(rtorrentStatusFields) {
+		values := make([]any, len(rtorrentStatusFields))
+		for j, res := range results[i : i+len(rtorrentStatusFields)] {
+			wrapped, ok := res.([]any)
+			if !ok || len(wrapped) == 0 {
+				values = nil
+				break
+			}
+			values[j] = wrapped[0]
+		}
+		if values == nil {
+			continue
+		}
+
+		if status, err := rtorrentStatus(values); err == nil {
+			statuses[status.ID] = status
+		}
+	}
+	return statuses, nil
+}
+
+func (r *RTorrentClient) ListTorrents() ([]TorrentStatus, error) {
+	params := []any{"", "main"}
+	for _, field := range rtorrentStatusFields {
+		params = append(params, field+"=")
+	}
+
+	result, err := r.call("d.multicall2", params...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := result.([]any)
+	var statuses []TorrentStatus
+	for _, row := range rows {
+		values, _ := row.([]any)
+		status, err := rtorrentStatus(values)
+		if err != nil {
+			continue
+		}
+
+		label, _ := values[13].(string)
+		if decoded, err := url.PathUnescape(label); err == nil {
+			label = decoded
+		}
+		if label == Category(r.config) {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
+
+// builds a status from the values of rtorrentStatusFields
+func rtorrentStatus(values []any) (*TorrentStatus, error) {
+	if len(values) != len(rtorrentStatusFields) {
+		return nil, fmt.Errorf("invalid status response")
+	}
+
+	str := func(i int) string {
+		s, _ := values[i].(string)
+		return s
+	}
+	num := func(i int) int64 {
+		n, _ := values[i].(int64)
+		return n
+	}
+
+	hash, name := str(0), str(1)
+	completed, total := num(4), num(5)
+	// a magnet is named <hash>.meta until its metadata arrived
+	fetchingMeta := strings.EqualFold(name, hash+".meta")
+
+	status := &TorrentStatus{
+		ID:            strings.ToLower(hash),
+		Name:          name,
+		Downloaded:    completed,
+		TotalSize:     total,
+		DownloadSpeed: num(6),
+		UploadSpeed:   num(7),
+		Uploaded:      num(8),
+		Seeders:       int(num(9)),
+		Peers:         int(num(10)),
+		SavePath:      str(11), // the torrent's own folder for multi file torrents
+		Error:         str(12),
+		Paused:        num(2) == 0,
+		IsComplete:    num(3) == 1 && !fetchingMeta,
+	}
+
+	status.State = "downloading"
+	switch {
+	case status.Paused:
+		status.State = "stopped"
+	case status.IsComplete:
+		status.State = "seeding"
+	}
+	if total > 0 {
+		status.Progress = float64(completed) / float64(total) * 100
+	}
+
+	return status, nil
+}
+
+func (r *RTorrentClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
+	hash := rtorrentHash(torrentID)
+
+	// read where the data is before the download is gone
+	var dataPath string
+	if deleteFiles {
+		var err error
+		if dataPath, err = r.dataPath(hash); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.call("d.erase", hash); err != nil {
+		return err
+	}
+
+	// rTorrent never deletes data itself
+	if deleteFiles && dataPath != "" {
+		_, err := deleteMappedData(r.config.PathMappings, dataPath)
+		return err
+	}
+	return nil
+}
+
+// the file or folder of a torrent's data. anything that could be the whole download directory is refused
+func (r *RTorrentClient) dataPath(hash string) (string, error) {
+	dir, err := r.call("d.directory", hash)
+	if err != nil {
+		return "", err
+	}
+	dataPath, _ := dir.(string)
+
+	multi, err := r.call("d.is_multi_file", hash)
+	if err != nil {
+		return "", err
+	}
+	// a single file torrent's directory is the folder it was downloaded to, its name is the file
+	if isMulti, _ := multi.(int64); isMulti == 0 {
+		name, err := r.call("d.name", hash)
+		if err != nil {
+			return "", err
+		}
+		nameStr, _ := name.(string)
+		if nameStr == "" || nameStr == "." || nameStr == ".." || strings.ContainsAny(nameStr, `/\`) {
+			return "", fmt.Errorf("not deleting the data of %s, rTorrent names it %q", hash, nameStr)
+		}
+		dataPath = strings.TrimRight(dataPath, "/") + "/" + nameStr
+	}
+
+	if strings.Trim(dataPath, "/") == "" {
+		return "", fmt.Errorf("not deleting the data of %s, rTorrent has no directory for it", hash)
+	}
+	local := filepath.Clean(shared.MapRemotePath(r.config.PathMappings, dataPath))
+	if def, err := r.call("directory.default"); err == nil {
+		if defStr, _ := def.(string); defStr != "" && filepath.Clean(shared.MapRemotePath(r.config.PathMappings, defStr)) == local {
+			return "", fmt.Errorf("not deleting the data of %s, it is rTorrent's download directory %s", hash, defStr)
+		}
+	}
+	return dataPath, nil
+}
+
+func (r *RTorrentClient) PauseTorrent(torrentID string) error {
+	_, err := r.call("d.stop", rtorrentHash(torrentID))
+	return err
+}
+
+func (r *RTorrentClient) ResumeTorrent(torrentID string) error {
+	_, err := r.call("d.start", rtorrentHash(torrentID))
+	return err
+}
+
+func (r *RTorrentClient) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	hash := rtorrentHash(torrentID)
+
+	name, err := r.call("d.name", hash)
+	if err != nil {
+		return nil, err
+	}
+	if nameStr, _ := name.(string); strings.EqualFold(nameStr, hash+".meta") {
+		return nil, nil // magnet metadata not there yet
+	}
+
+	result, err := r.call("f.multicall", hash, "", "f.path=", "f.size_bytes=", "f.completed_chunks=", "f.size_chunks=")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := result.([]any)
+	files := make([]TorrentFile, 0, len(rows))
+	for i, row := range rows {
+		values, _ := row.([]any)
+		if len(values) != 4 {
+			continue
+		}
+
+		path, _ := values[0].(string)
+		size, _ := values[1].(int64)
+		done, _ := values[2].(int64)
+		chunks, _ := values[3].(int64)
+
+		tf := TorrentFile{
+			Index: i,
+			Path:  path, // relative to d.directory
+			Size:  size,
+		}
+		if chunks > 0 {
+			tf.Progress = float64(done) / float64(chunks) * 100
+		}
+
+		files = append(files, tf)
+	}
+
+	return files, nil
+}
+
+func (r *RTorrentClient) SetFilePriorities(torrentID string, wanted []bool) error {
+	hash := rtorrentHash(torrentID)
+
+	for i, w := range wanted {
+		priority := 0 // off
+		if w {
+			priority = 1 // normal
+		}
+		if _, err := r.call("f.priority.set", fmt.Sprintf("%s:f%d", hash, i), priority); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.call("d.update_priorities", hash)
+	return err
+}
+
+func (r *RTorrentClient) GetClientInfo() (*ClientInfo, error) {
+	version, err := r.call("system.client_version")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ClientInfo{}
+	info.Version, _ = version.(string)
+
+	// free space on the download directories, older versions don't have it
+	if free, err := r.call("system.free_diskspace"); err == nil {
+		info.FreeSpace, _ = free.(int64)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a download in the fake rTorrent, keyed by its upper case hash
+type fakeRTorrentDownload struct {
+	name  string
+	dir   string // d.directory, the torrent's own folder for multi file torrents
+	multi bool
+	label string // d.custom1 as ruTorrent stores it
+}
+
+// a fake rTorrent behind an XML-RPC gateway. unknown hashes fault like rTorrent does
+func fakeRTorrent(t *testing.T, calls *[]string, downloads map[string]*fakeRTorrentDownload) *httptest.Server {
+	field := func(hash string, d *fakeRTorrentDownload, name string) any {
+		switch name {
+		case "d.hash":
+			return hash
+		case "d.name":
+			return d.name
+		case "d.state", "d.complete":
+			return int64(1)
+		case "d.completed_bytes", "d.size_bytes":
+			return int64(1000)
+		case "d.directory":
+			return d.dir
+		case "d.custom1":
+			return d.label
+		case "d.is_multi_file":
+			if d.multi {
+				return int64(1)
+			}
+			return int64(0)
+		case "d.message":
+			return ""
+		default:
+			return int64(0)
+		}
+	}
+	unknown := map[string]any{"faultCode": int64(-501), "faultString": "Could not find info-hash."}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `xml:"methodName"`
+			Params []xmlrpcValue `xml:"params>param>value"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		*calls = append(*calls, req.Method)
+
+		params := make([]any, len(req.Params))
+		for i, p := range req.Params {
+			params[i] = p.value()
+		}
+
+		reply := func(result any) {
+			var buf bytes.Buffer
+			buf.WriteString("<methodResponse><params><param>")
+			if err := xmlrpcEncodeValue(&buf, result); err != nil {
+				t.Errorf("can't reply to %s: %v", req.Method, err)
+			}
+			buf.WriteString("</param></params></methodResponse>")
+			w.Write(buf.Bytes())
+		}
+		fault := func() {
+			var buf bytes.Buffer
+			buf.WriteString("<methodResponse><fault>")
+			xmlrpcEncodeValue(&buf, unknown)
+			buf.WriteString("</fault></methodResponse>")
+			w.Write(buf.Bytes())
+		}
+		download := func() (string, *fakeRTorrentDownload) {
+			hash, _ := params[0].(string)
+			return hash, downloads[hash]
+		}
+
+		switch req.Method {
+		case "system.client_version":
+			reply("0.9.8")
+		case "directory.default":
+			reply("/downloads")
+		case "system.multicall":
+			batch, _ := params[0].([]any)
+			results := make([]any, len(batch))
+			for i, c := range batch {
+				call, _ := c.(map[string]any)
+				method, _ := call["methodName"].(string)
+				args, _ := call["params"].([]any)
+				hash, _ := args[0].(string)
+				if d, ok := downloads[hash]; ok {
+					results[i] = []any{field(hash, d, method)}
+				} else {
+					results[i] = unknown
+				}
+			}
+			reply(results)
+		case "d.multicall2":
+			var rows []any
+			for hash, d := range downloads {
+				var row []any
+				for _, p := range params[2:] {
+					name, _ := p.(string)
+					row = append(row, field(hash, d, name[:len(name)-1]))
+				}
+				rows = append(rows, row)
+			}
+			reply(rows)
+		case "d.name", "d.directory", "d.is_multi_file":
+			if hash, d := download(); d != nil {
+				reply(field(hash, d, req.Method))
+			} else {
+				fault()
+			}
+		case "d.erase":
+			if hash, d := download(); d != nil {
+				delete(downloads, hash)
+				reply(int64(0))
+			} else {
+				fault()
+			}
+		default:
+			t.Errorf("unexpected call %s", req.Method)
+			fault()
+		}
+	}))
+}
+
+func TestRTorrentStatuses(t *testing.T) {
+	other := "0123456789abcdef0123456789abcdef01234567"
+	missing := "ffffffffffffffffffffffffffffffffffffffff"
+	downloads := map[string]*fakeRTorrentDownload{
+		rtorrentHash(testHash): {name: "[One Pace][1-7] Romance Dawn [1080p]", dir: "/downloads/[One Pace][1-7] Romance Dawn [1080p]", multi: true},
+		rtorrentHash(other):    {name: "[One Pace][8-11] Orange Town [720p].mkv", dir: "/downloads"},
+	}
+
+	var calls []string
+	srv := fakeRTorrent(t, &calls, downloads)
+	defer srv.Close()
+
+	c, err := NewRTorrentClient(shared.TorrentClientConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := c.GetTorrentStatuses([]string{testHash, missing, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want the 2 known torrents: %v", len(statuses), statuses)
+	}
+	if _, ok := statuses[missing]; ok {
+		t.Error("the faulted hash got a status")
+	}
+
+	status := statuses[testHash]
+	if status == nil || !status.IsComplete || status.SavePath != "/downloads/[One Pace][1-7] Romance Dawn [1080p]" {
+		t.Errorf("status = %+v", status)
+	}
+	if statuses[other] == nil || statuses[other].Name != "[One Pace][8-11] Orange Town [720p].mkv" {
+		t.Errorf("the torrent after the faulted one = %+v", statuses[other])
+	}
+
+	if _, err := c.GetTorrentStatus(missing); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("expected ErrTorrentNotFound, got %v", err)
+	}
+}
+
+func TestRTorrentListTorrentsByLabel(t *testing.T) {
+	downloads := map[string]*fakeRTorrentDownload{
+		"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA": {name: "encoded", label: "One%20Pace"}, // ruTorrent url-encodes labels
+		"BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB": {name: "plain", label: "One Pace"},
+		"CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC": {name: "other", label: "Movies"},
+		"DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDD": {name: "unlabeled"},
+	}
+
+	var calls []string
+	srv := fakeRTorrent(t, &calls, downloads)
+	defer srv.Close()
+
+	c, err := NewRTorrentClient(shared.TorrentClientConfig{URL: srv.URL, Category: "One Pace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	torrents, err := c.ListTorrents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	for _, torrent := range torrents {
+		names[torrent.Name] = true
+	}
+	if len(names) != 2 || !names["encoded"] || !names["plain"] {
+		t.Errorf("listed %v, want the encoded and plain One Pace labels", names)
+	}
+}
+
+func TestRTorrentRemoveTorrent(t *testing.T) {
+	local := t.TempDir()
+	for _, path := range []string{"ep1.mkv", "keep.mkv", "Arc/ep2.mkv"} {
+		writeSized(t, filepath.Join(local, path), 4)
+	}
+
+	single := "1111111111111111111111111111111111111111"
+	multi := "2222222222222222222222222222222222222222"
+	kept := "3333333333333333333333333333333333333333"
+	downloads := map[string]*fakeRTorrentDownload{
+		rtorrentHash(single): {name: "ep1.mkv", dir: "/downloads"},
+		rtorrentHash(multi):  {name: "Arc", dir: "/downloads/Arc", multi: true},
+		rtorrentHash(kept):   {name: "keep.mkv", dir: "/downloads"},
+	}
+
+	var calls []string
+	srv := fakeRTorrent(t, &calls, downloads)
+	defer srv.Close()
+
+	c, err := NewRTorrentClient(shared.TorrentClientConfig{
+		URL:          srv.URL,
+		PathMappings: []shared.PathMapping{{Remote: "/downloads", Local: local}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a single file torrent's directory is the folder it is in, only the file goes
+	if err := c.RemoveTorrent(single, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "ep1.mkv")); !os.IsNotExist(err) {
+		t.Errorf("the single file is still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "Arc", "ep2.mkv")); err != nil {
+		t.Errorf("the folder it was in lost other data: %v", err)
+	}
+
+	// a multi file torrent's directory is its own folder
+	if err := c.RemoveTorrent(multi, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "Arc")); !os.IsNotExist(err) {
+		t.Errorf("the torrent folder is still there: %v", err)
+	}
+
+	if err := c.RemoveTorrent(kept, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "keep.mkv")); err != nil {
+		t.Errorf("data was deleted without deleteFiles: %v", err)
+	}
+	if _, err := os.Stat(local); err != nil {
+		t.Errorf("the download dir itself is gone: %v", err)
+	}
+
+	if len(downloads) != 0 {
+		t.Errorf("%d torrents left in rTorrent", len(downloads))
+	}
+
+	if err := c.RemoveTorrent(single, true); err == nil {
+		t.Error("removing an unknown torrent should fail")
+	}
+}
+
+func TestRTorrentRemoveTorrentKeepsDownloadDir(t *testing.T) {
+	local := t.TempDir()
+	writeSized(t, filepath.Join(local, "other.mkv"), 4)
+
+	unnamed := "1111111111111111111111111111111111111111"
+	whole := "2222222222222222222222222222222222222222"
+	downloads := map[string]*fakeRTorrentDownload{
+		rtorrentHash(unnamed): {name: "", dir: "/downloads"},
+		rtorrentHash(whole):   {name: "Arc", dir: "/downloads/", multi: true},
+	}
+
+	var calls []string
+	srv := fakeRTorrent(t, &calls, downloads)
+	defer srv.Close()
+
+	c, err := NewRTorrentClient(shared.TorrentClientConfig{
+		URL:          srv.URL,
+		PathMappings: []shared.PathMapping{{Remote: "/downloads", Local: local}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a single file torrent without a name would be its whole folder
+	if err := c.RemoveTorrent(unnamed, true); err == nil {
+		t.Error("expected an error for a torrent without a name")
+	}
+	// a multi file torrent in the download directory itself
+	if err := c.RemoveTorrent(whole, true); err == nil {
+		t.Error("expected an error for a torrent whose folder is the download directory")
+	}
+
+	if _, err := os.Stat(filepath.Join(local, "other.mkv")); err != nil {
+		t.Errorf("data in the download directory was deleted: %v", err)
+	}
+	if len(downloads) != 2 {
+		t.Errorf("a torrent was erased although its data was kept: %d left", len(downloads))
+	}
+}
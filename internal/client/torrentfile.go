@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// .torrent files are small, anything bigger is not one
+const maxTorrentFileSize = 10 << 20
+
+// indexers that hang don't hold up queueing
+var fetchClient = &http.Client{Timeout: requestTimeout}
+
+// FetchTorrentFile downloads a .torrent, for clients that can't reach the indexer themselves
+func FetchTorrentFile(ctx context.Context, torrentURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, torrentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch torrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch torrent: unexpected status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTorrentFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch torrent: %w", err)
+	}
+
+	return data, nil
+}
+
+// InfoHashOf returns the infohash of .torrent contents as lowercase hex
+func InfoHashOf(data []byte) (string, error) {
+	meta, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent: %w", err)
+	}
+	return meta.HashInfoBytes().HexString(), nil
+}
+
+// TotalSizeOf returns the size of every file in .torrent contents together
+func TotalSizeOf(data []byte) (int64, error) {
+	meta, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid torrent: %w", err)
+	}
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		return 0, fmt.Errorf("invalid torrent info: %w", err)
+	}
+	return info.TotalLength(), nil
+}
@@ -3,15 +3,22 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type TransmissionClient struct {
 	config    shared.TorrentClientConfig
 	client    *http.Client
+	mu        sync.Mutex
 	sessionID string
 }
 
@@ -21,14 +28,45 @@ type transmissionRequest struct {
 }
 
 type transmissionResponse struct {
-	Result    string `json:"result"`
-	Arguments any    `json:"arguments,omitempty"`
+	Result    string          `json:"result"` // "success" or the error
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// the fields of torrent-get we read, see transmissionStatusFields
+type transmissionTorrent struct {
+	ID               int                `json:"id"`
+	HashString       string             `json:"hashString"`
+	Name             string             `json:"name"`
+	Status           int                `json:"status"` // index into transmissionStates
+	PercentDone      float64            `json:"percentDone"`
+	DownloadedEver   int64              `json:"downloadedEver"`
+	TotalSize        int64              `json:"totalSize"`
+	RateDownload     int64              `json:"rateDownload"`
+	RateUpload       int64              `json:"rateUpload"`
+	DownloadDir      string             `json:"downloadDir"`
+	Error            int                `json:"error"` // 0 is none
+	ErrorString      string             `json:"errorString"`
+	PeersSendingToUs int                `json:"peersSendingToUs"`
+	PeersConnected   int                `json:"peersConnected"`
+	Labels           []string           `json:"labels"`
+	UploadedEver     int64              `json:"uploadedEver"`
+	SecondsSeeding   int64              `json:"secondsSeeding"`
+	Files            []transmissionFile `json:"files"`
 }
 
+type transmissionFile struct {
+	Name           string `json:"name"`
+	Length         int64  `json:"length"`
+	BytesCompleted int64  `json:"bytesCompleted"`
+}
+
+// tr_torrent_activity, by the status number
+var transmissionStates = []string{"stopped", "check pending", "checking", "download pending", "downloading", "seed pending", "seeding"}
+
 func NewTransmissionClient(cfg shared.TorrentClientConfig) (*TransmissionClient, error) {
 	client := &TransmissionClient{
 		config: cfg,
-		client: &http.Client{},
+		client: &http.Client{Timeout: requestTimeout},
 	}
 
 	if err := client.TestConnection(); err != nil {
@@ -38,7 +76,18 @@ func NewTransmissionClient(cfg shared.TorrentClientConfig) (*TransmissionClient,
 	return client, nil
 }
 
+// makeRequest retries reads when the connection fails
 func (t *TransmissionClient) makeRequest(req transmissionRequest, resp *transmissionResponse) error {
+	idempotent := strings.HasSuffix(req.Method, "-get")
+	return withRetry(idempotent, func() error {
+		*resp = transmissionResponse{}
+		return t.send(req, resp, true)
+	})
+}
+
+// send posts the request. Transmission answers 409 with a new session id when ours expired,
+// then the request is sent again once with it
+func (t *TransmissionClient) send(req transmissionRequest, resp *transmissionResponse, renew bool) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return err
@@ -50,9 +99,11 @@ func (t *TransmissionClient) makeRequest(req transmissionRequest, resp *transmis
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	t.mu.Lock()
 	if t.sessionID != "" {
 		httpReq.Header.Set("X-Transmission-Session-Id", t.sessionID)
 	}
+	t.mu.Unlock()
 
 	if t.config.Username != "" {
 		httpReq.SetBasicAuth(t.config.Username, t.config.Password)
@@ -64,149 +115,313 @@ func (t *TransmissionClient) makeRequest(req transmissionRequest, resp *transmis
 	}
 	defer httpResp.Body.Close()
 
-	if httpResp.StatusCode == 409 {
+	if httpResp.StatusCode == http.StatusConflict && renew {
+		t.mu.Lock()
 		t.sessionID = httpResp.Header.Get("X-Transmission-Session-Id")
-		return t.makeRequest(req, resp)
+		t.mu.Unlock()
+		return t.send(req, resp, false)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed with status: %d", req.Method, httpResp.StatusCode)
 	}
 
 	return json.NewDecoder(httpResp.Body).Decode(resp)
 }
 
-func (t *TransmissionClient) TestConnection() error {
-	req := transmissionRequest{
-		Method: "session-get",
+// call runs method and decodes its arguments into result, unless that is nil. a result other than success is an error
+func (t *TransmissionClient) call(method string, args any, result any) error {
+	var resp transmissionResponse
+	if err := t.makeRequest(transmissionRequest{Method: method, Arguments: args}, &resp); err != nil {
+		return err
+	}
+	if resp.Result != "success" {
+		return fmt.Errorf("%s: %s", method, resp.Result)
 	}
 
-	var resp transmissionResponse
-	return t.makeRequest(req, &resp)
+	if result == nil || len(resp.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Arguments, result); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
+	return nil
+}
+
+func (t *TransmissionClient) TestConnection() error {
+	return t.call("session-get", nil, nil)
+}
+
+func (t *TransmissionClient) AddTorrent(ctx context.Context, torrentURL string, opts AddOptions) (string, error) {
+	args := transmissionAddArgs(opts)
+	args["filename"] = torrentURL
+
+	return t.add(args, opts)
 }
 
-func (t *TransmissionClient) AddTorrent(ctx context.Context, torrentURL string, savePath string) (string, error) {
-	req := transmissionRequest{
-		Method: "torrent-add",
-		Arguments: map[string]any{
-			"filename":     torrentURL,
-			"download-dir": savePath,
-		},
+func (t *TransmissionClient) AddTorrentFile(ctx context.Context, data []byte, opts AddOptions) (string, error) {
+	hash, err := InfoHashOf(data)
+	if err != nil {
+		return "", err
 	}
 
-	var resp transmissionResponse
-	if err := t.makeRequest(req, &resp); err != nil {
+	args := transmissionAddArgs(opts)
+	args["metainfo"] = base64.StdEncoding.EncodeToString(data)
+
+	if _, err := t.add(args, opts); err != nil {
 		return "", err
 	}
 
-	if resp.Result != "success" {
-		return "", fmt.Errorf("failed to add torrent: %s", resp.Result)
+	return hash, nil
+}
+
+// torrent-add arguments without the torrent itself. seed limits can only be set after adding
+func transmissionAddArgs(opts AddOptions) map[string]any {
+	args := map[string]any{"paused": opts.Paused}
+	if opts.SavePath != "" {
+		args["download-dir"] = opts.SavePath
+	}
+	if opts.Category != "" {
+		args["labels"] = []string{opts.Category}
+	}
+	if opts.Sequential {
+		args["sequential_download"] = true // transmission 4.1+, ignored before
+	}
+	return args
+}
+
+// adds the torrent and sets its seed ratio
+func (t *TransmissionClient) add(args map[string]any, opts AddOptions) (string, error) {
+	hash, err := t.addTorrent(args)
+	if err != nil || opts.SeedRatio <= 0 {
+		return hash, err
 	}
 
-	argsMap, ok := resp.Arguments.(map[string]any)
-	if !ok {
-		return "", fmt.Errorf("invalid response")
+	// transmission has no total seed time limit, only the ratio is set
+	err = t.call("torrent-set", map[string]any{
+		"ids":            []string{hash},
+		"seedRatioLimit": opts.SeedRatio,
+		"seedRatioMode":  1, // use the torrent's own limit
+	}, nil)
+	if err != nil {
+		logger.Log(false, "Could not set the seed ratio of %s in Transmission: %v", hash, err)
 	}
 
-	torrentAdded, ok := argsMap["torrent-added"].(map[string]any)
-	if !ok {
+	return hash, nil
+}
+
+// torrent-add, returns the hash of the added or already present torrent
+func (t *TransmissionClient) addTorrent(args map[string]any) (string, error) {
+	var added struct {
+		Added     *transmissionTorrent `json:"torrent-added"`
+		Duplicate *transmissionTorrent `json:"torrent-duplicate"` // already in the client, e.g the same release from another indexer
+	}
+	if err := t.call("torrent-add", args, &added); err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+
+	torrent := added.Added
+	if torrent == nil {
+		torrent = added.Duplicate
+	}
+	if torrent == nil || torrent.HashString == "" {
 		return "", fmt.Errorf("torrent not added")
 	}
 
-	hashString, _ := torrentAdded["hashString"].(string)
-	return hashString, nil
+	return torrent.HashString, nil
 }
 
+var transmissionStatusFields = []string{"id", "hashString", "name", "status", "percentDone", "downloadedEver", "totalSize", "rateDownload", "rateUpload", "downloadDir", "error", "errorString", "peersSendingToUs", "peersConnected", "labels", "uploadedEver", "secondsSeeding"}
+
 func (t *TransmissionClient) GetTorrentStatus(torrentID string) (*TorrentStatus, error) {
-	req := transmissionRequest{
-		Method: "torrent-get",
-		Arguments: map[string]any{
-			"fields": []string{"id", "name", "status", "percentDone", "downloadedEver", "totalSize", "rateDownload", "rateUpload", "downloadDir", "error", "errorString"},
-			"ids":    []string{torrentID},
-		},
+	torrents, err := t.getTorrents([]string{torrentID}, transmissionStatusFields)
+	if err != nil {
+		return nil, err
 	}
 
-	var resp transmissionResponse
-	if err := t.makeRequest(req, &resp); err != nil {
+	if len(torrents) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTorrentNotFound, torrentID)
+	}
+
+	return torrents[0].status(), nil
+}
+
+func (t *TransmissionClient) GetTorrentStatuses(torrentIDs []string) (map[string]*TorrentStatus, error) {
+	statuses := make(map[string]*TorrentStatus, len(torrentIDs))
+	if len(torrentIDs) == 0 {
+		return statuses, nil // no ids would get every torrent
+	}
+
+	torrents, err := t.getTorrents(torrentIDs, transmissionStatusFields)
+	if err != nil {
 		return nil, err
 	}
 
-	argsMap := resp.Arguments.(map[string]any)
-	torrents := argsMap["torrents"].([]any)
+	for _, torrent := range torrents {
+		statuses[strings.ToLower(torrent.HashString)] = torrent.status()
+	}
+	return statuses, nil
+}
 
-	if len(torrents) == 0 {
-		return nil, fmt.Errorf("torrent not found")
+// labels need transmission 3.0 or newer, older versions list nothing
+func (t *TransmissionClient) ListTorrents() ([]TorrentStatus, error) {
+	torrents, err := t.getTorrents(nil, transmissionStatusFields)
+	if err != nil {
+		return nil, err
+	}
+
+	label := Category(t.config)
+	var statuses []TorrentStatus
+	for _, torrent := range torrents {
+		if slices.Contains(torrent.Labels, label) {
+			statuses = append(statuses, *torrent.status())
+		}
 	}
+	return statuses, nil
+}
 
-	torrent := torrents[0].(map[string]any)
-	percentDone := torrent["percentDone"].(float64)
+// torrent-get for ids, all torrents if ids is nil
+func (t *TransmissionClient) getTorrents(ids []string, fields []string) ([]transmissionTorrent, error) {
+	args := map[string]any{"fields": fields}
+	if ids != nil {
+		args["ids"] = ids
+	}
 
+	var result struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}
+	if err := t.call("torrent-get", args, &result); err != nil {
+		return nil, err
+	}
+	return result.Torrents, nil
+}
+
+// status with the hash as ID
+func (torrent *transmissionTorrent) status() *TorrentStatus {
 	status := &TorrentStatus{
-		ID:            fmt.Sprintf("%v", torrent["id"]),
-		Name:          torrent["name"].(string),
-		Progress:      percentDone * 100,
-		Downloaded:    int64(torrent["downloadedEver"].(float64)),
-		TotalSize:     int64(torrent["totalSize"].(float64)),
-		DownloadSpeed: int64(torrent["rateDownload"].(float64)),
-		UploadSpeed:   int64(torrent["rateUpload"].(float64)),
-		SavePath:      torrent["downloadDir"].(string),
-		IsComplete:    percentDone >= 1.0,
+		ID:            torrent.HashString,
+		Name:          torrent.Name,
+		Progress:      torrent.PercentDone * 100,
+		Downloaded:    torrent.DownloadedEver,
+		TotalSize:     torrent.TotalSize,
+		DownloadSpeed: torrent.RateDownload,
+		UploadSpeed:   torrent.RateUpload,
+		Uploaded:      torrent.UploadedEver,
+		Paused:        torrent.Status == 0, // stopped
+		SeedingTime:   time.Duration(torrent.SecondsSeeding) * time.Second,
+		Seeders:       torrent.PeersSendingToUs,
+		Peers:         torrent.PeersConnected,
+		SavePath:      torrent.DownloadDir,
+		IsComplete:    torrent.PercentDone >= 1.0,
 	}
 
-	if errNum, ok := torrent["error"].(float64); ok && errNum != 0 {
-		status.Error = torrent["errorString"].(string)
+	if torrent.Status >= 0 && torrent.Status < len(transmissionStates) {
+		status.State = transmissionStates[torrent.Status]
+	}
+	if torrent.Error != 0 {
+		status.Error = torrent.ErrorString
 	}
 
-	return status, nil
+	return status
 }
 
 func (t *TransmissionClient) RemoveTorrent(torrentID string, deleteFiles bool) error {
-	req := transmissionRequest{
-		Method: "torrent-remove",
-		Arguments: map[string]any{
-			"ids":               []string{torrentID},
-			"delete-local-data": deleteFiles,
-		},
-	}
-
-	var resp transmissionResponse
-	return t.makeRequest(req, &resp)
+	return t.call("torrent-remove", map[string]any{
+		"ids":               []string{torrentID},
+		"delete-local-data": deleteFiles,
+	}, nil)
 }
 
 func (t *TransmissionClient) PauseTorrent(torrentID string) error {
-	req := transmissionRequest{
-		Method: "torrent-stop",
-		Arguments: map[string]any{
-			"ids": []string{torrentID},
-		},
+	return t.call("torrent-stop", map[string]any{"ids": []string{torrentID}}, nil)
+}
+
+func (t *TransmissionClient) ResumeTorrent(torrentID string) error {
+	return t.call("torrent-start", map[string]any{"ids": []string{torrentID}}, nil)
+}
+
+func (t *TransmissionClient) GetTorrentFiles(torrentID string) ([]TorrentFile, error) {
+	torrents, err := t.getTorrents([]string{torrentID}, []string{"hashString", "files"})
+	if err != nil {
+		return nil, err
+	}
+	if len(torrents) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTorrentNotFound, torrentID)
 	}
 
-	var resp transmissionResponse
-	return t.makeRequest(req, &resp)
+	files := torrents[0].Files
+	result := make([]TorrentFile, 0, len(files))
+	for i, f := range files {
+		tf := TorrentFile{
+			Index: i,
+			Path:  f.Name,
+			Size:  f.Length,
+		}
+		if f.Length > 0 {
+			tf.Progress = float64(f.BytesCompleted) / float64(f.Length) * 100
+		}
+
+		result = append(result, tf)
+	}
+
+	return result, nil
 }
 
-func (t *TransmissionClient) ResumeTorrent(torrentID string) error {
-	req := transmissionRequest{
-		Method: "torrent-start",
-		Arguments: map[string]any{
-			"ids": []string{torrentID},
-		},
+func (t *TransmissionClient) SetFilePriorities(torrentID string, wanted []bool) error {
+	var keep, skip []int
+	for i, w := range wanted {
+		if w {
+			keep = append(keep, i)
+		} else {
+			skip = append(skip, i)
+		}
 	}
 
-	var resp transmissionResponse
-	return t.makeRequest(req, &resp)
+	args := map[string]any{
+		"ids": []string{torrentID},
+	}
+	// an empty list means every file to transmission, so leave it out
+	if len(keep) > 0 {
+		args["files-wanted"] = keep
+	}
+	if len(skip) > 0 {
+		args["files-unwanted"] = skip
+	}
+
+	if err := t.call("torrent-set", args, nil); err != nil {
+		return fmt.Errorf("failed to set file priorities: %w", err)
+	}
+
+	return nil
 }
 
 func (t *TransmissionClient) GetClientInfo() (*ClientInfo, error) {
-	req := transmissionRequest{
-		Method: "session-get",
+	var session struct {
+		Version     string `json:"version"`
+		DownloadDir string `json:"download-dir"`
 	}
-
-	var resp transmissionResponse
-	if err := t.makeRequest(req, &resp); err != nil {
+	if err := t.call("session-get", nil, &session); err != nil {
 		return nil, err
 	}
 
-	argsMap := resp.Arguments.(map[string]any)
-	version, _ := argsMap["version"].(string)
+	info := &ClientInfo{
+		Version: session.Version,
+	}
+
+	// free space on the save path, the session's download dir if none is set
+	path := t.config.SavePath
+	if path == "" {
+		path = session.DownloadDir
+	}
+	var space struct {
+		SizeBytes int64 `json:"size-bytes"`
+		TotalSize int64 `json:"total_size"` // 4.0 and later
+	}
+	if err := t.call("free-space", map[string]any{"path": path}, &space); err != nil {
+		logger.Log(false, "Transmission: could not get free space: %v", err)
+	} else {
+		info.FreeSpace = space.SizeBytes
+		info.TotalSpace = space.TotalSize
+	}
 
-	return &ClientInfo{
-		Version: version,
-	}, nil
+	return info, nil
 }
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"opforjellyfin/internal/shared"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// a Transmission daemon that wants the session id handshake first
+func fakeTransmission(t *testing.T, calls *[]string) *httptest.Server {
+	return fakeRecorded(t, "transmission", func(w http.ResponseWriter, r *http.Request, body []byte) string {
+		if r.Header.Get("X-Transmission-Session-Id") != "abc" {
+			w.Header().Set("X-Transmission-Session-Id", "abc")
+			w.WriteHeader(http.StatusConflict)
+			return ""
+		}
+
+		var req struct {
+			Method    string `json:"method"`
+			Arguments struct {
+				IDs    []string `json:"ids"`
+				Fields []string `json:"fields"`
+			} `json:"arguments"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return ""
+		}
+		*calls = append(*calls, req.Method)
+
+		if req.Method == "torrent-get" {
+			switch {
+			case len(req.Arguments.IDs) > 0 && !slices.Contains(req.Arguments.IDs, testHash):
+				return "torrent-get.unknown"
+			case slices.Contains(req.Arguments.Fields, "files"):
+				return "torrent-get.files"
+			}
+		}
+		return req.Method
+	})
+}
+
+func TestTransmissionClient(t *testing.T) {
+	var calls []string
+	srv := fakeTransmission(t, &calls)
+	defer srv.Close()
+
+	c, err := NewTransmissionClient(shared.TorrentClientConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.GetTorrentStatus(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TorrentStatus{
+		ID: testHash, Name: "[One Pace][1-7] Romance Dawn [1080p]", State: "downloading", Progress: 42.5,
+		Downloaded: 425000000, TotalSize: 1000000000, DownloadSpeed: 2048000, Uploaded: 12000000, Seeders: 3, Peers: 7,
+		SavePath: "/downloads", Error: "No data found! Ensure your drives are connected",
+	}
+	if *status != want {
+		t.Errorf("status = %+v, want %+v", *status, want)
+	}
+
+	if _, err := c.GetTorrentStatus("0000000000000000000000000000000000000000"); !errors.Is(err, ErrTorrentNotFound) {
+		t.Errorf("expected ErrTorrentNotFound for an unknown hash, got %v", err)
+	}
+
+	statuses, err := c.GetTorrentStatuses([]string{testHash})
+	if err != nil || statuses[testHash] == nil {
+		t.Errorf("GetTorrentStatuses = %+v, %v", statuses, err)
+	}
+
+	torrents, err := c.ListTorrents()
+	if err != nil || len(torrents) != 1 {
+		t.Errorf("ListTorrents = %+v, %v", torrents, err)
+	}
+
+	files, err := c.GetTorrentFiles(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Progress != 100 || files[1].Progress != 0 || files[1].Index != 1 {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	// already in the client, the seed ratio can't be set but the torrent is there
+	hash, err := c.AddTorrent(context.Background(), "https://example.com/romance-dawn.torrent", AddOptions{SeedRatio: 0.6})
+	if err != nil || hash != testHash {
+		t.Errorf("AddTorrent = %q, %v", hash, err)
+	}
+
+	if err := c.SetFilePriorities(testHash, []bool{true, false}); err == nil || !strings.Contains(err.Error(), "invalid argument") {
+		t.Errorf("expected the RPC result as error, got %v", err)
+	}
+
+	info, err := c.GetClientInfo()
+	if err != nil || info.Version != "4.0.5 (a6fe2a64aa)" || info.FreeSpace != 50<<30 || info.TotalSpace != 500107862016 {
+		t.Errorf("GetClientInfo = %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// just enough XML-RPC for rTorrent. values decode to string, int64, bool, float64, []byte, []any or map[string]any
+
+func xmlrpcEncode(method string, params []any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodCall><methodName>")
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString("</methodName><params>")
+
+	for _, p := range params {
+		buf.WriteString("<param>")
+		if err := xmlrpcEncodeValue(&buf, p); err != nil {
+			return nil, err
+		}
+		buf.WriteString("</param>")
+	}
+
+	buf.WriteString("</params></methodCall>")
+	return buf.Bytes(), nil
+}
+
+func xmlrpcEncodeValue(buf *bytes.Buffer, v any) error {
+	buf.WriteString("<value>")
+
+	switch v := v.(type) {
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<i8>%d</i8>", v)
+	case int64:
+		fmt.Fprintf(buf, "<i8>%d</i8>", v)
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		fmt.Fprintf(buf, "<boolean>%d</boolean>", b)
+	case []byte:
+		buf.WriteString("<base64>")
+		buf.WriteString(base64.StdEncoding.EncodeToString(v))
+		buf.WriteString("</base64>")
+	case []string:
+		items := make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		if err := xmlrpcEncodeArray(buf, items); err != nil {
+			return err
+		}
+	case []any:
+		if err := xmlrpcEncodeArray(buf, v); err != nil {
+			return err
+		}
+	case map[string]any:
+		buf.WriteString("<struct>")
+		for name, member := range v {
+			buf.WriteString("<member><name>")
+			xml.EscapeText(buf, []byte(name))
+			buf.WriteString("</name>")
+			if err := xmlrpcEncodeValue(buf, member); err != nil {
+				return err
+			}
+			buf.WriteString("</member>")
+		}
+		buf.WriteString("</struct>")
+	default:
+		return fmt.Errorf("xmlrpc: can't encode %T", v)
+	}
+
+	buf.WriteString("</value>")
+	return nil
+}
+
+func xmlrpcEncodeArray(buf *bytes.Buffer, items []any) error {
+	buf.WriteString("<array><data>")
+	for _, item := range items {
+		if err := xmlrpcEncodeValue(buf, item); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("</data></array>")
+	return nil
+}
+
+type xmlrpcValue struct {
+	String  *string `xml:"string"`
+	I4      *string `xml:"i4"`
+	I8      *string `xml:"i8"`
+	Int     *string `xml:"int"`
+	Boolean *string `xml:"boolean"`
+	Double  *string `xml:"double"`
+	Base64  *string `xml:"base64"`
+	Array   *struct {
+		Values []xmlrpcValue `xml:"data>value"`
+	} `xml:"array"`
+	Struct *struct {
+		Members []struct {
+			Name  string      `xml:"name"`
+			Value xmlrpcValue `xml:"value"`
+		} `xml:"member"`
+	} `xml:"struct"`
+	Text string `xml:",chardata"` // a value without a type is a string
+}
+
+type xmlrpcResponse struct {
+	Params []xmlrpcValue `xml:"params>param>value"`
+	Fault  *xmlrpcValue  `xml:"fault>value"`
+}
+
+// decodes a methodResponse, a fault is returned as an error
+func xmlrpcDecode(r io.Reader) (any, error) {
+	var resp xmlrpcResponse
+	if err := xml.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid XML-RPC response: %w", err)
+	}
+
+	if resp.Fault != nil {
+		fault, _ := resp.Fault.value().(map[string]any)
+		return nil, fmt.Errorf("fault %v: %v", fault["faultCode"], fault["faultString"])
+	}
+	if len(resp.Params) == 0 {
+		return nil, nil
+	}
+	return resp.Params[0].value(), nil
+}
+
+func (v xmlrpcValue) value() any {
+	switch {
+	case v.String != nil:
+		return *v.String
+	case v.I4 != nil:
+		return xmlrpcInt(*v.I4)
+	case v.I8 != nil:
+		return xmlrpcInt(*v.I8)
+	case v.Int != nil:
+		return xmlrpcInt(*v.Int)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1"
+	case v.Double != nil:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+		return f
+	case v.Base64 != nil:
+		data, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(*v.Base64))
+		return data
+	case v.Array != nil:
+		items := make([]any, len(v.Array.Values))
+		for i, item := range v.Array.Values {
+			items[i] = item.value()
+		}
+		return items
+	case v.Struct != nil:
+		members := make(map[string]any, len(v.Struct.Members))
+		for _, m := range v.Struct.Members {
+			members[m.Name] = m.Value.value()
+		}
+		return members
+	default:
+		return v.Text
+	}
+}
+
+func xmlrpcInt(s string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLRPCDecode(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<methodResponse><params><param><value><array><data>
+  <value><array><data><value><string>ABC</string></value><value><i8>1048576</i8></value><value>untyped</value></data></array></value>
+  <value><struct><member><name>ok</name><value><boolean>1</boolean></value></member></struct></value>
+</data></array></value></param></params></methodResponse>`
+
+	got, err := xmlrpcDecode(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []any{
+		[]any{"ABC", int64(1048576), "untyped"},
+		map[string]any{"ok": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	fault := `<methodResponse><fault><value><struct>
+  <member><name>faultCode</name><value><i4>-501</i4></value></member>
+  <member><name>faultString</name><value><string>Could not find info-hash.</string></value></member>
+</struct></value></fault></methodResponse>`
+
+	if _, err := xmlrpcDecode(strings.NewReader(fault)); err == nil || !strings.Contains(err.Error(), "Could not find info-hash") {
+		t.Errorf("expected the fault as error, got %v", err)
+	}
+}
+
+func TestXMLRPCEncode(t *testing.T) {
+	body, err := xmlrpcEncode("load.raw_start", []any{"", []byte("d4:infoe"), []string{"a&b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range []string{
+		"<methodName>load.raw_start</methodName>",
+		"<value><string></string></value>",
+		"<value><base64>ZDQ6aW5mb2U=</base64></value>",
+		"<value><array><data><value><string>a&amp;b</string></value></data></array></value>",
+	} {
+		if !strings.Contains(string(body), part) {
+			t.Errorf("missing %s in %s", part, body)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/client"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"time"
+)
+
+// ErrNoSpace is wrapped by errors for releases that don't fit on disk with the reserve kept free
+var ErrNoSpace = errors.New("not enough disk space")
+
+// why a download that doesn't fit yet is pending
+const waitingForSpace = "Waiting for disk space"
+
+// DiskSpace is the free space where downloads are written, for the System page and CLI
+type DiskSpace struct {
+	Name  string `json:"name"` // e.g "client qbittorrent" or "library nas"
+	Path  string `json:"path"`
+	Free  int64  `json:"free"` // bytes, 0 if unknown
+	Total int64  `json:"total"`
+	Low   bool   `json:"low"` // below the warning threshold
+	Error string `json:"error,omitempty"`
+}
+
+// a disk a download takes space on, uses is what a running download still writes there
+type destination struct {
+	DiskSpace
+	uses func(td *shared.TorrentDownload) int64
+}
+
+// DiskSpaces returns the free space of every client that can take downloads and every library
+func DiskSpaces(cfg shared.Config) []DiskSpace {
+	var spaces []DiskSpace
+	if !cfg.TorrentClient.External() {
+		spaces = append(spaces, clientDestination(cfg.TorrentClient, cfg).DiskSpace)
+	}
+	for _, tc := range cfg.ExternalClients() {
+		spaces = append(spaces, clientDestination(tc, cfg).DiskSpace)
+	}
+	for _, lib := range cfg.AllLibraries() {
+		spaces = append(spaces, libraryDestination(lib, cfg).DiskSpace)
+	}
+	return spaces
+}
+
+// LowSpace returns the disks below the warning threshold
+func LowSpace(cfg shared.Config) []DiskSpace {
+	var low []DiskSpace
+	for _, space := range DiskSpaces(cfg) {
+		if space.Low {
+			low = append(low, space)
+		}
+	}
+	return low
+}
+
+// CheckSpace returns an error wrapping ErrNoSpace if a release downloaded by tc doesn't fit on the client's disk
+// or in the libraries it is placed in, next to what the busy downloads still write. unknown sizes and free space pass
+func CheckSpace(rel shared.Release, tc shared.TorrentClientConfig, cfg shared.Config, busy []*shared.TorrentDownload) error {
+	if rel.Size <= 0 {
+		return nil
+	}
+
+	dests := []destination{clientDestination(tc, cfg)}
+	for _, lib := range cfg.LibrariesFor(rel.Quality) {
+		if lib.Placement == shared.PlacementSymlink {
+			// links take no space
+			continue
+		}
+		dests = append(dests, libraryDestination(lib, cfg))
+	}
+
+	for _, d := range dests {
+		if d.Free <= 0 {
+			continue
+		}
+
+		needed := rel.Size + cfg.Disk.Reserve()
+		for _, td := range busy {
+			needed += d.uses(td)
+		}
+		if needed > d.Free {
+			return fmt.Errorf("%w on %s: %s free, %s needed with running downloads and the %s reserve",
+				ErrNoSpace, d.Name, shared.FormatGB(d.Free), shared.FormatGB(needed), shared.FormatGB(cfg.Disk.Reserve()))
+		}
+	}
+	return nil
+}
+
+// how long reading the size of a release may take before the indexer's size is used
+const fetchTimeout = 20 * time.Second
+
+// ReleaseSize returns the size of a release from its .torrent, or the size the indexer lists
+// for magnets and torrents that can't be fetched. 0 if unknown
+func ReleaseSize(ctx context.Context, entry shared.TorrentEntry, torrentURL string) int64 {
+	size, _ := fetchRelease(ctx, entry, torrentURL)
+	return size
+}
+
+// the size of a release and its .torrent contents, nil for magnets and torrents that can't be fetched
+func fetchRelease(ctx context.Context, entry shared.TorrentEntry, torrentURL string) (int64, []byte) {
+	if shared.IsMagnet(torrentURL) {
+		return entry.Size, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	data, err := client.FetchTorrentFile(ctx, torrentURL)
+	if err == nil {
+		var size int64
+		if size, err = client.TotalSizeOf(data); err == nil {
+			return size, data
+		}
+	}
+
+	logger.Log(false, "Could not read the size of %s: %v", entry.TorrentName, err)
+	return entry.Size, nil
+}
+
+// downloads writing to disk, other than skip. pending ones don't count, they are checked when they start
+func busyDownloads(skip *shared.TorrentDownload) []*shared.TorrentDownload {
+	var busy []*shared.TorrentDownload
+	for _, td := range shared.GetActiveDownloads() {
+		if td != skip && !td.Pending && !td.Imported && !td.Failed() {
+			busy = append(busy, td)
+		}
+	}
+	return busy
+}
+
+// the disk tc downloads to. external clients report it themselves, the internal one writes to the incomplete dir
+func clientDestination(tc shared.TorrentClientConfig, cfg shared.Config) destination {
+	remaining := func(td *shared.TorrentDownload) int64 {
+		return max(td.TotalSize-td.Progress, 0)
+	}
+
+	if !tc.External() {
+		return destination{
+			DiskSpace: localSpace("internal client", torrent.IncompleteDir(cfg), cfg),
+			uses: func(td *shared.TorrentDownload) int64 {
+				if td.UseExternal {
+					return 0
+				}
+				return remaining(td)
+			},
+		}
+	}
+
+	d := destination{
+		DiskSpace: DiskSpace{Name: "client " + tc.ClientName(), Path: tc.SavePath},
+		uses: func(td *shared.TorrentDownload) int64 {
+			if !td.UseExternal || cfg.ClientFor(td).ClientName() != tc.ClientName() {
+				return 0
+			}
+			return remaining(td)
+		},
+	}
+
+	torrentClient, err := client.NewClient(tc)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	info, err := torrentClient.GetClientInfo()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	if info.FreeSpace <= 0 {
+		d.Error = "the client doesn't report free space"
+		return d
+	}
+
+	d.Free, d.Total = info.FreeSpace, info.TotalSpace
+	d.Low = d.Free < cfg.Disk.Warn()
+	return d
+}
+
+// the disk of a library, unplaced downloads it accepts will be placed there
+func libraryDestination(lib shared.LibraryConfig, cfg shared.Config) destination {
+	return destination{
+		DiskSpace: localSpace("library "+lib.Name, lib.TargetDir, cfg),
+		uses: func(td *shared.TorrentDownload) int64 {
+			if td.Placed || !lib.Accepts(td.Quality) {
+				return 0
+			}
+			return td.TotalSize
+		},
+	}
+}
+
+func localSpace(name, path string, cfg shared.Config) DiskSpace {
+	space := DiskSpace{Name: name, Path: path}
+
+	free, total, err := shared.DiskUsage(path)
+	if err != nil {
+		space.Error = err.Error()
+		return space
+	}
+
+	space.Free, space.Total = free, total
+	space.Low = free < cfg.Disk.Warn()
+	return space
+}
@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a config downloading into temp dirs, with the 1 MB reserve and the free space of the disk they are on
+func spaceConfig(t *testing.T) (shared.Config, int64) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() {
+		for _, td := range shared.GetActiveDownloads() {
+			shared.RemoveDownload(td.TorrentID)
+		}
+	})
+
+	cfg := shared.Config{Disk: shared.DiskConfig{ReserveMB: 1}}
+	cfg.TorrentClient.IncompleteDir = t.TempDir()
+
+	free, _, err := shared.DiskUsage(cfg.TorrentClient.IncompleteDir)
+	if err != nil {
+		t.Skipf("free space is not known here: %v", err)
+	}
+	if free < 64<<20 {
+		t.Skipf("only %s free", shared.FormatGB(free))
+	}
+	return cfg, free
+}
+
+// a blackhole client on the same disk, it reports the free space of its completed folder
+func blackhole(t *testing.T, name string) shared.TorrentClientConfig {
+	return shared.TorrentClientConfig{Name: name, Type: "blackhole", WatchDir: t.TempDir(), CompletedDir: t.TempDir()}
+}
+
+func TestCheckSpace(t *testing.T) {
+	cfg, free := spaceConfig(t)
+	cfg.TargetDir = t.TempDir()
+
+	if err := CheckSpace(shared.Release{Size: free / 4}, shared.TorrentClientConfig{}, cfg, nil); err != nil {
+		t.Errorf("a quarter of the disk doesn't fit: %v", err)
+	}
+	if err := CheckSpace(shared.Release{Size: free * 2}, shared.TorrentClientConfig{}, cfg, nil); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("twice the disk fits: %v", err)
+	}
+	if err := CheckSpace(shared.Release{}, shared.TorrentClientConfig{}, cfg, nil); err != nil {
+		t.Errorf("unknown sizes should pass: %v", err)
+	}
+
+	// the client and the library share the disk here, each is checked on its own
+	if err := CheckSpace(shared.Release{Size: free * 2 / 3}, shared.TorrentClientConfig{}, cfg, nil); err != nil {
+		t.Errorf("each destination is checked on its own: %v", err)
+	}
+
+	// what running downloads still write counts, finished ones don't
+	running := &shared.TorrentDownload{TotalSize: free / 2, Progress: free / 8}
+	if err := CheckSpace(shared.Release{Size: free / 2}, shared.TorrentClientConfig{}, cfg, []*shared.TorrentDownload{running}); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("fits next to a running download: %v", err)
+	}
+	running.Placed, running.Progress = true, running.TotalSize
+	if err := CheckSpace(shared.Release{Size: free / 2}, shared.TorrentClientConfig{}, cfg, []*shared.TorrentDownload{running}); err != nil {
+		t.Errorf("a finished download still takes space: %v", err)
+	}
+
+	// symlinked libraries take no space, only the client disk is left to check
+	cfg.Libraries = []shared.LibraryConfig{{Name: "links", TargetDir: cfg.TargetDir, Placement: shared.PlacementSymlink}}
+	if err := CheckSpace(shared.Release{Size: free / 4}, shared.TorrentClientConfig{}, cfg, []*shared.TorrentDownload{{TotalSize: free}}); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("internal downloads should count: %v", err)
+	}
+	if err := CheckSpace(shared.Release{Size: free / 4}, shared.TorrentClientConfig{}, cfg, []*shared.TorrentDownload{{UseExternal: true, TotalSize: free}}); err != nil {
+		t.Errorf("external downloads don't use the internal client's disk: %v", err)
+	}
+}
+
+func TestFitsSomewhere(t *testing.T) {
+	cfg, free := spaceConfig(t)
+
+	if err := fitsSomewhere(shared.Release{Size: free * 2}, nil, cfg); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("too big for the internal client: %v", err)
+	}
+
+	full := blackhole(t, "full")
+	if err := fitsSomewhere(shared.Release{Size: free * 2}, []shared.TorrentClientConfig{full, blackhole(t, "also-full")}, cfg); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("too big for every client: %v", err)
+	}
+
+	// a client that is down doesn't report its free space, the release may fit there
+	down := shared.TorrentClientConfig{Name: "down", Type: "transmission", URL: "http://127.0.0.1:1"}
+	if err := fitsSomewhere(shared.Release{Size: free * 2}, []shared.TorrentClientConfig{full, down}, cfg); err != nil {
+		t.Errorf("should fit on the client with unknown space: %v", err)
+	}
+
+	if err := fitsSomewhere(shared.Release{Size: free / 4}, []shared.TorrentClientConfig{full}, cfg); err != nil {
+		t.Errorf("fits with nothing else downloading: %v", err)
+	}
+}
+
+func TestQueueWaitsForSpace(t *testing.T) {
+	cfg, free := spaceConfig(t)
+	cfg.TorrentClient = blackhole(t, "queue")
+
+	// fills most of the disk once it finishes
+	shared.SaveTorrentDownload(&shared.TorrentDownload{
+		TorrentID: 1, UseExternal: true, Client: "queue", ExternalHash: "1111111111111111111111111111111111111111", TotalSize: free * 3 / 4,
+	})
+
+	entry := &shared.TorrentEntry{
+		TorrentID:   2,
+		TorrentName: "[One Pace][1-7] Romance Dawn [1080p]",
+		Quality:     "1080p",
+		Size:        free / 2,
+		Magnet:      "magnet:?xt=urn:btih:2222222222222222222222222222222222222222&dn=Romance+Dawn",
+	}
+	if err := QueueDownload(context.Background(), entry, entry.Magnet, cfg); err != nil {
+		t.Fatalf("a release that fits once the other download is done was refused: %v", err)
+	}
+
+	td := shared.FindDownload(2, "")
+	if td == nil {
+		t.Fatal("not queued")
+	}
+	if !td.Pending || td.PlacementProgress != "⏳ "+waitingForSpace {
+		t.Errorf("pending = %v (%q), want waiting for space", td.Pending, td.PlacementProgress)
+	}
+	if watched, _ := os.ReadDir(cfg.TorrentClient.WatchDir); len(watched) > 0 {
+		t.Errorf("added to the client anyway: %s", watched[0].Name())
+	}
+
+	// room once the other download is gone
+	shared.RemoveDownload(1)
+	if err := startExternalDownload(context.Background(), td, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if td.Pending {
+		t.Error("still pending")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.TorrentClient.WatchDir, "2222222222222222222222222222222222222222.magnet")); err != nil {
+		t.Errorf("not handed to the client: %v", err)
+	}
+
+	// too big even with nothing else downloading
+	entry = &shared.TorrentEntry{TorrentID: 3, TorrentName: "huge", Size: free * 2, Magnet: "magnet:?xt=urn:btih:3333333333333333333333333333333333333333"}
+	if err := QueueDownload(context.Background(), entry, entry.Magnet, cfg); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("queued a release bigger than the disk: %v", err)
+	}
+	if shared.FindDownload(3, "") != nil {
+		t.Error("a refused release is in the queue")
+	}
+}
@@ -2,31 +2,79 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"opforjellyfin/internal/client"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/matcher"
 	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
-func QueueDownload(entry *shared.TorrentEntry, torrentURL string, cfg shared.Config) error {
-	if client.IsInternalClient(cfg.TorrentClient) {
-		return queueInternalDownload(entry)
+// QueueDownload queues a release, external ones are added to a client right away when they can start.
+// the .torrent is fetched once, for its size and for clients it is uploaded to
+func QueueDownload(ctx context.Context, entry *shared.TorrentEntry, torrentURL string, cfg shared.Config) error {
+	if existing := shared.FindDownload(entry.TorrentID, entry.InfoHash); existing != nil && !existing.Imported && !existing.Failed() {
+		return fmt.Errorf("%s is already queued", existing.Title)
 	}
 
-	return queueExternalDownload(entry, torrentURL, cfg)
+	release := entry.Release()
+	var data []byte
+	release.Size, data = fetchRelease(ctx, *entry, torrentURL)
+
+	candidates := cfg.ClientsFor(release)
+	if err := fitsSomewhere(release, candidates, cfg); err != nil {
+		return err
+	}
+
+	if len(candidates) == 0 {
+		return queueInternalDownload(entry, release.Size)
+	}
+
+	return queueExternalDownload(ctx, entry, torrentURL, release.Size, data, cfg)
+}
+
+// refuses releases too big for every candidate client even with nothing else downloading.
+// ones that fit once running downloads finish are queued and wait for space
+func fitsSomewhere(release shared.Release, candidates []shared.TorrentClientConfig, cfg shared.Config) error {
+	if len(candidates) == 0 {
+		return CheckSpace(release, shared.TorrentClientConfig{}, cfg, nil)
+	}
+
+	var errs []error
+	for _, tc := range candidates {
+		err := CheckSpace(release, tc, cfg, nil)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// title of downloads queued from the web UI
+func queueTitle(entry shared.TorrentEntry) string {
+	return entry.TorrentName
 }
 
-func queueInternalDownload(entry *shared.TorrentEntry) error {
+func queueInternalDownload(entry *shared.TorrentEntry, size int64) error {
 	td := &shared.TorrentDownload{
-		Title:        entry.TorrentName,
+		Title:        queueTitle(*entry),
 		TorrentID:    entry.TorrentID,
 		FullTitle:    entry.Title,
 		Started:      time.Now(),
 		ChapterRange: entry.ChapterRange,
+		Quality:      entry.Quality,
+		Magnet:       entry.Magnet,
+		InfoHash:     entry.InfoHash,
 		UseExternal:  false,
+		TotalSize:    size,
 	}
 
 	shared.SaveTorrentDownload(td)
@@ -35,47 +83,366 @@ func queueInternalDownload(entry *shared.TorrentEntry) error {
 	return nil
 }
 
-func queueExternalDownload(entry *shared.TorrentEntry, torrentURL string, cfg shared.Config) error {
-	torrentClient, err := client.NewClient(cfg.TorrentClient)
-	if err != nil {
-		return fmt.Errorf("failed to create torrent client: %w", err)
-	}
-
-	ctx := context.Background()
-
-	hash, err := torrentClient.AddTorrent(ctx, torrentURL, "")
-	if err != nil {
-		return fmt.Errorf("failed to add torrent to client: %w", err)
-	}
-
+func queueExternalDownload(ctx context.Context, entry *shared.TorrentEntry, torrentURL string, size int64, data []byte, cfg shared.Config) error {
 	td := &shared.TorrentDownload{
-		Title:        entry.TorrentName,
+		Title:        queueTitle(*entry),
 		TorrentID:    entry.TorrentID,
 		FullTitle:    entry.Title,
 		Started:      time.Now(),
 		ChapterRange: entry.ChapterRange,
-		ExternalHash: hash,
+		Quality:      entry.Quality,
+		Magnet:       entry.Magnet,
+		InfoHash:     entry.InfoHash,
 		UseExternal:  true,
 		Imported:     false,
+		TorrentURL:   torrentURL,
+		TotalSize:    size,
+		Bundle:       entry.IsBundle,
+	}
+
+	// the worker adds it once a window opens or a slot frees up
+	if reason := pendingReason(cfg.Schedule, runningExternal()); reason != "" {
+		td.MarkPending(reason)
+		logger.Log(false, "Queued external download: %s (%s)", entry.TorrentName, reason)
+		return nil
+	}
+
+	if err := startExternalDownload(ctx, td, data, cfg); errors.Is(err, ErrNoSpace) {
+		td.MarkPending(waitingForSpace)
+		logger.Log(true, "⚠️  Queued %s, it waits for disk space: %v", entry.TorrentName, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	logger.Log(false, "Queued external download: %s (hash: %s, client: %s)", entry.TorrentName, td.ExternalHash, td.Client)
+	return nil
+}
+
+// adds a queued download to the first client that takes it. data is the .torrent if it was fetched already
+func startExternalDownload(ctx context.Context, td *shared.TorrentDownload, data []byte, cfg shared.Config) error {
+	added, err := addRouted(ctx, td, td.Release(), td.TorrentURL, data, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to add torrent to client: %w", err)
+	}
+
+	td.ExternalHash = added.hash
+	td.InfoHash = strings.ToLower(added.hash)
+	td.Client = added.config.ClientName()
+	td.AddedToClient = added.isNew
+	td.Pending = false
+	td.PlacementProgress = ""
+
+	// the client may not know the files yet, the worker tries again
+	if err := selectExternalFiles(added.client, td, cfg); err != nil {
+		logger.Log(false, "Could not select files for %s yet: %v", td.Title, err)
 	}
 
 	shared.SaveTorrentDownload(td)
-	logger.Log(false, "Queued external download: %s (hash: %s)", entry.TorrentName, hash)
+	return nil
+}
+
+// a release handed to a client
+type addedTorrent struct {
+	client client.TorrentClient
+	config shared.TorrentClientConfig
+	hash   string
+	isNew  bool // false if the client had it already, or it can't be told
+}
+
+// adds the release of td to the clients its rules allow, preferred first, moving on to the next one
+// when a client is down or out of space
+func addRouted(ctx context.Context, td *shared.TorrentDownload, release shared.Release, torrentURL string, data []byte, cfg shared.Config) (*addedTorrent, error) {
+	candidates := cfg.ClientsFor(release)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no external torrent client configured")
+	}
+
+	busy := busyDownloads(td)
+	var errs []error
+	for _, tc := range candidates {
+		var torrentClient client.TorrentClient
+		err := CheckSpace(release, tc, cfg, busy)
+		if err == nil {
+			torrentClient, err = client.NewClient(tc)
+		}
+		if err == nil {
+			var added *addedTorrent
+			if added, err = addToClient(ctx, torrentClient, tc, torrentURL, data, cfg); err == nil {
+				return added, nil
+			}
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", tc.ClientName(), err))
+		if len(candidates) > 1 {
+			logger.Log(true, "⚠️  Could not add to %s, trying the next client: %v", tc.ClientName(), err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// adds a .torrent URL or magnet, uploading the .torrent contents if the client can't reach the indexer.
+// clients return the hash of a torrent they already have, so the hash is looked up first to tell the two apart.
+// data is fetched here unless the caller has it
+func addToClient(ctx context.Context, torrentClient client.TorrentClient, tc shared.TorrentClientConfig, torrentURL string, data []byte, cfg shared.Config) (*addedTorrent, error) {
+	known := shared.InfoHashFromMagnet(torrentURL)
+	if !shared.IsMagnet(torrentURL) && data == nil {
+		var err error
+		if data, err = client.FetchTorrentFile(ctx, torrentURL); err != nil && tc.UploadTorrents {
+			return nil, err
+		}
+	}
+	if data != nil {
+		known, _ = client.InfoHashOf(data)
+	}
+
+	isNew := false
+	if known != "" {
+		_, err := torrentClient.GetTorrentStatus(known)
+		isNew = errors.Is(err, client.ErrTorrentNotFound)
+	}
+
+	var hash string
+	var err error
+	if tc.UploadTorrents && data != nil {
+		hash, err = torrentClient.AddTorrentFile(ctx, data, client.Options(cfg, tc))
+	} else {
+		hash, err = torrentClient.AddTorrent(ctx, torrentURL, client.Options(cfg, tc))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &addedTorrent{client: torrentClient, config: tc, hash: hash, isNew: isNew}, nil
+}
+
+// the client that has the download
+func clientFor(td *shared.TorrentDownload, cfg shared.Config) (client.TorrentClient, error) {
+	tc := cfg.ClientFor(td)
+	if !tc.External() {
+		return nil, fmt.Errorf("torrent client %q is no longer configured", td.Client)
+	}
+
+	torrentClient, err := client.NewClient(tc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create torrent client %s: %w", tc.ClientName(), err)
+	}
+	return torrentClient, nil
+}
 
+// why a download can't start now, empty if it can
+func pendingReason(schedule shared.ScheduleConfig, running int) string {
+	now := time.Now()
+	if !schedule.Open(now) {
+		if next := schedule.NextOpen(now); !next.IsZero() {
+			return "Waiting until " + next.Format("15:04")
+		}
+		return "No valid download window"
+	}
+	if running >= schedule.Concurrent() {
+		return "Waiting for a free slot"
+	}
+	return ""
+}
+
+// external downloads added to the client and not imported yet
+func runningExternal() int {
+	running := 0
+	for _, td := range shared.GetActiveDownloads() {
+		if td.UseExternal && !td.Pending && !td.Imported && !td.Failed() && td.ExternalHash != "" {
+			running++
+		}
+	}
+	return running
+}
+
+// skips files for episodes we already have. marks td imported if nothing is missing
+func selectExternalFiles(torrentClient client.TorrentClient, td *shared.TorrentDownload, cfg shared.Config) error {
+	files, err := torrentClient.GetTorrentFiles(td.ExternalHash)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no file list yet")
+	}
+
+	paths := make([]string, len(files))
+	maxIndex := 0
+	for i, f := range files {
+		paths[i] = f.Path
+		maxIndex = max(maxIndex, f.Index)
+	}
+
+	selected := matcher.SelectMissingFiles(paths, cfg.LibrariesFor(td.Quality), metadata.LoadMetadataCache(), td.ChapterRange)
+
+	wanted := make([]bool, maxIndex+1)
+	td.SelectedFiles = []string{}
+	for i, f := range files {
+		if selected[i] {
+			wanted[f.Index] = true
+			td.SelectedFiles = append(td.SelectedFiles, f.Path)
+		}
+	}
+	if len(td.SelectedFiles) == 0 {
+		logger.Log(true, "📦 %s: every episode is already in your library", td.Title)
+		// a torrent the client had before may be seeding, or be what symlinked libraries point at
+		if td.AddedToClient {
+			if err := torrentClient.RemoveTorrent(td.ExternalHash, false); err != nil {
+				logger.Log(false, "Could not remove %s: %v", td.ExternalHash, err)
+			}
+		}
+		td.Done = true
+		td.Imported = true
+		td.FilesSelected = true
+		td.MarkPlaced("✅ Already have every episode")
+		return nil
+	}
+
+	if len(td.SelectedFiles) < len(files) {
+		logger.Log(true, "📦 %s: downloading %d of %d files", td.Title, len(td.SelectedFiles), len(files))
+	}
+
+	if err := torrentClient.SetFilePriorities(td.ExternalHash, wanted); err != nil {
+		return err
+	}
+
+	td.FilesSelected = true
 	return nil
 }
 
-func GetDownloadStatus(td *shared.TorrentDownload, cfg shared.Config) (*client.TorrentStatus, error) {
-	if !td.UseExternal {
-		return nil, nil
+// adds the next best release for the same chapters and removes the stalled one from the external client.
+// the stalled torrent stays if there is nothing to switch to
+func switchExternalRelease(td *shared.TorrentDownload, cfg shared.Config) error {
+	if td.Attempt+1 >= cfg.Retry.Attempts() {
+		return fmt.Errorf("stalled after %d release(s)", td.Attempt+1)
 	}
 
-	torrentClient, err := client.NewClient(cfg.TorrentClient)
+	stalledHash, stalledAdded := td.ExternalHash, td.AddedToClient
+	stalledClient, stalledErr := clientFor(td, cfg)
+
+	entries, err := scraper.FetchTorrents(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create torrent client: %w", err)
+		return fmt.Errorf("could not look for alternatives: %w", err)
+	}
+
+	alt := scraper.FindAlternative(entries, td.ChapterRange, td.Quality, map[int]bool{td.TorrentID: true})
+	if alt == nil {
+		return fmt.Errorf("no other release for %s", td.ChapterRange)
+	}
+
+	torrentURL := alt.Source(cfg.Source.BaseURL)
+	added, err := addRouted(context.Background(), td, alt.Release(), torrentURL, nil, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", alt.Title, err)
+	}
+
+	logger.Log(true, "🔁 Switching to %s (%s, %d seeders)", alt.Title, alt.Quality, alt.Seeders)
+	td.SwitchRelease(*alt, queueTitle(*alt))
+	td.ExternalHash = added.hash
+	td.InfoHash = strings.ToLower(added.hash)
+	td.TorrentURL = torrentURL
+	td.Client = added.config.ClientName()
+	td.AddedToClient = added.isNew
+	td.Bundle = alt.IsBundle
+
+	if err := selectExternalFiles(added.client, td, cfg); err != nil {
+		logger.Log(false, "Could not select files for %s yet: %v", alt.Title, err)
+	}
+	shared.SaveTorrentDownload(td)
+
+	// only torrents opforjellyfin added are removed, with their partial data
+	if !stalledAdded {
+		return nil
+	}
+	if stalledErr != nil {
+		logger.Log(false, "Could not remove stalled torrent %s: %v", stalledHash, stalledErr)
+	} else if err := stalledClient.RemoveTorrent(stalledHash, true); err != nil {
+		logger.Log(false, "Could not remove stalled torrent %s: %v", stalledHash, err)
 	}
 
-	return torrentClient.GetTorrentStatus(td.ExternalHash)
+	return nil
+}
+
+// client statuses of external downloads, shared by the worker and every browser tab
+var statusCache = struct {
+	sync.Mutex
+	statuses  map[string]*client.TorrentStatus // by lower case hash
+	requested map[string]bool                  // hashes the clients answered for, found or not
+	fetched   time.Time
+}{}
+
+// how long the web UI reuses statuses, it polls every few seconds per tab
+const StatusMaxAge = 5 * time.Second
+
+// GetDownloadStatuses returns the client status of each active external download by lower case hash,
+// asking each client once for all of its downloads. statuses younger than maxAge are reused,
+// downloads the client doesn't know are left out. a client that can't be reached is returned as error
+// next to the statuses of the others
+func GetDownloadStatuses(downloads []*shared.TorrentDownload, cfg shared.Config, maxAge time.Duration) (map[string]*client.TorrentStatus, error) {
+	var hashes []string
+	byClient := map[string][]*shared.TorrentDownload{}
+	for _, td := range downloads {
+		if hasClientStatus(td) {
+			hashes = append(hashes, td.ExternalHash)
+			name := cfg.ClientFor(td).ClientName()
+			byClient[name] = append(byClient[name], td)
+		}
+	}
+	if len(hashes) == 0 {
+		return map[string]*client.TorrentStatus{}, nil
+	}
+
+	statusCache.Lock()
+	defer statusCache.Unlock()
+
+	if time.Since(statusCache.fetched) < maxAge && cachedAll(hashes) {
+		return statusCache.statuses, nil
+	}
+
+	statuses := map[string]*client.TorrentStatus{}
+	requested := map[string]bool{}
+	var errs []error
+	for _, owned := range byClient {
+		torrentClient, err := clientFor(owned[0], cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		clientHashes := make([]string, len(owned))
+		for i, td := range owned {
+			clientHashes[i] = td.ExternalHash
+		}
+		found, err := torrentClient.GetTorrentStatuses(clientHashes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.ClientFor(owned[0]).ClientName(), err))
+			continue
+		}
+		maps.Copy(statuses, found)
+		for _, hash := range clientHashes {
+			requested[strings.ToLower(hash)] = true
+		}
+	}
+
+	statusCache.statuses = statuses
+	statusCache.requested = requested
+	statusCache.fetched = time.Now()
+	return statuses, errors.Join(errs...)
+}
+
+// a download added since the last fetch isn't cached yet. one the client didn't know is, as not found.
+// callers hold statusCache
+func cachedAll(hashes []string) bool {
+	for _, hash := range hashes {
+		if !statusCache.requested[strings.ToLower(hash)] {
+			return false
+		}
+	}
+	return true
+}
+
+// downloads a client is asked about, pending ones aren't in a client yet
+func hasClientStatus(td *shared.TorrentDownload) bool {
+	return td.UseExternal && !td.Pending && td.ExternalHash != ""
 }
 
 func TestConnection(cfg shared.TorrentClientConfig) (string, error) {
@@ -93,7 +460,14 @@ func TestConnection(cfg shared.TorrentClientConfig) (string, error) {
 		return cfg.Type, nil
 	}
 
-	return fmt.Sprintf("%s v%s", cfg.Type, info.Version), nil
+	name := cfg.Type
+	if info.Version != "" {
+		name += " v" + info.Version
+	}
+	if info.FreeSpace > 0 {
+		name += fmt.Sprintf(" (%.1f GB free)", float64(info.FreeSpace)/(1<<30))
+	}
+	return name, nil
 }
 
 func ImportCompletedDownload(td *shared.TorrentDownload, status *client.TorrentStatus, cfg shared.Config) error {
@@ -106,11 +480,20 @@ func ImportCompletedDownload(td *shared.TorrentDownload, status *client.TorrentS
 		return fmt.Errorf("torrent not complete")
 	}
 
-	td.SavePath = status.SavePath
+	// the client may see the files under another path, e.g in its own container
+	savePath := shared.MapRemotePath(cfg.ClientFor(td).PathMappings, status.SavePath)
+	if savePath != status.SavePath {
+		logger.Log(false, "Mapped client path %s to %s", status.SavePath, savePath)
+	}
+	if _, err := os.Stat(savePath); err != nil {
+		return fmt.Errorf("save path %s is not reachable here, add a remote path mapping: %w", savePath, err)
+	}
+
+	td.SavePath = savePath
 	td.PlacementProgress = "🔗 Importing files..."
 	shared.SaveTorrentDownload(td)
 
-	logger.Log(true, "🔄 Starting import for: %s from %s", td.Title, status.SavePath)
+	logger.Log(true, "🔄 Starting import for: %s from %s", td.Title, savePath)
 
 	index := metadata.LoadMetadataCache()
 	if index == nil {
@@ -119,7 +502,7 @@ func ImportCompletedDownload(td *shared.TorrentDownload, status *client.TorrentS
 	}
 
 	// Process the files and check if any were placed
-	matcher.ProcessTorrentFiles(status.SavePath, cfg.TargetDir, td, index)
+	matcher.ProcessTorrentFiles(savePath, cfg.LibrariesFor(td.Quality), td, index)
 
 	// Only mark as imported and placed if files were actually placed
 	if len(td.PlacementFull) > 0 {
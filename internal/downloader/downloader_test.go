@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"opforjellyfin/internal/shared"
+	"testing"
+	"time"
+)
+
+func TestStatusCacheKeepsNotFound(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := shared.Config{TorrentClient: blackhole(t, "statuses")}
+	gone := &shared.TorrentDownload{TorrentID: 1, UseExternal: true, Client: "statuses", ExternalHash: "1111111111111111111111111111111111111111"}
+
+	statuses, err := GetDownloadStatuses([]*shared.TorrentDownload{gone}, cfg, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 0 {
+		t.Fatalf("the client doesn't know the torrent, got %v", statuses)
+	}
+	fetched := statusCache.fetched
+
+	if _, err := GetDownloadStatuses([]*shared.TorrentDownload{gone}, cfg, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !statusCache.fetched.Equal(fetched) {
+		t.Error("a torrent the client didn't know was asked about again")
+	}
+
+	added := &shared.TorrentDownload{TorrentID: 2, UseExternal: true, Client: "statuses", ExternalHash: "2222222222222222222222222222222222222222"}
+	if _, err := GetDownloadStatuses([]*shared.TorrentDownload{gone, added}, cfg, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if statusCache.fetched.Equal(fetched) {
+		t.Error("a download added since the last fetch was served from the cache")
+	}
+}
+
+func TestAdoptSkipsClientsWithoutLabels(t *testing.T) {
+	// listing would fail, aria2 isn't running. it must not even be asked
+	cfg := shared.Config{TorrentClient: shared.TorrentClientConfig{Name: "shared-aria2", Type: "aria2", URL: "http://127.0.0.1:1"}}
+
+	adopted, err := AdoptTorrents(cfg, nil)
+	if err != nil || len(adopted) != 0 {
+		t.Errorf("adopted %v from a client without labels (%v)", adopted, err)
+	}
+}
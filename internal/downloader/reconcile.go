@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/client"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/scraper"
+	"opforjellyfin/internal/shared"
+	"strings"
+	"time"
+)
+
+// AdoptTorrents tracks One Pace torrents in the client's category that opforjellyfin doesn't know about,
+// e.g added by hand or left over from lost state, so the worker imports them. skip holds hashes that were handled already.
+// clients without labels are skipped, every torrent in them would count
+func AdoptTorrents(cfg shared.Config, skip map[string]bool) ([]*shared.TorrentDownload, error) {
+	var adopted []*shared.TorrentDownload
+	var errs []error
+	for _, tc := range cfg.ExternalClients() {
+		if !client.HasLabels(tc) {
+			continue
+		}
+
+		found, err := adoptFrom(tc, skip)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tc.ClientName(), err))
+		}
+		adopted = append(adopted, found...)
+	}
+	return adopted, errors.Join(errs...)
+}
+
+func adoptFrom(tc shared.TorrentClientConfig, skip map[string]bool) ([]*shared.TorrentDownload, error) {
+	torrentClient, err := client.NewClient(tc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create torrent client: %w", err)
+	}
+
+	torrents, err := torrentClient.ListTorrents()
+	if err != nil {
+		return nil, fmt.Errorf("could not list torrents: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, td := range shared.GetActiveDownloads() {
+		known[strings.ToLower(td.ExternalHash)] = true
+		known[td.InfoHash] = true
+	}
+
+	var adopted []*shared.TorrentDownload
+	for _, t := range torrents {
+		hash := strings.ToLower(t.ID)
+		if hash == "" || known[hash] || skip[hash] {
+			continue
+		}
+
+		chapterRange := shared.ExtractChapterRangeFromTitle(t.Name)
+		if !metadata.HaveMetadata(chapterRange) {
+			logger.Log(false, "Reconcile: %s does not match any episode, skipping", t.Name)
+			continue
+		}
+
+		// already imported, the client is only seeding it
+		if metadata.HaveVideoStatus(chapterRange) == 2 {
+			continue
+		}
+
+		td := &shared.TorrentDownload{
+			Title:        scraper.ExtractTorrentName(t.Name),
+			TorrentID:    shared.IDFromInfoHash(hash),
+			FullTitle:    t.Name,
+			Started:      time.Now(),
+			ChapterRange: chapterRange,
+			Quality:      scraper.ParseQuality(t.Name),
+			ExternalHash: t.ID,
+			InfoHash:     hash,
+			UseExternal:  true,
+			Client:       tc.ClientName(),
+			Bundle:       metadata.IsArcRelease(chapterRange),
+			// files were picked by whoever added it
+			FilesSelected: true,
+		}
+		shared.SaveTorrentDownload(td)
+		adopted = append(adopted, td)
+
+		logger.Log(true, "📥 Adopted %s from %s", t.Name, tc.ClientName())
+	}
+
+	return adopted, nil
+}
@@ -0,0 +1,208 @@
+// downloader/seeding.go
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/client"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/matcher"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// an imported download left seeding in the external client, removed with its data once the seeding policy is reached
+type ClientSeed struct {
+	Hash     string            `json:"hash"`             // what the client knows the torrent by
+	Client   string            `json:"client,omitempty"` // name of the client seeding it, empty for the primary one
+	Title    string            `json:"title"`
+	SavePath string            `json:"save_path"` // local path of the torrent's files
+	Files    map[string]string `json:"files"`     // torrent file path -> placed library file
+	Imported time.Time         `json:"imported"`
+}
+
+// client seed jobs survive restarts, the torrent keeps seeding while serve is down
+func clientSeedDir() string {
+	return filepath.Join(shared.ConfigDir(), "client-seeding")
+}
+
+func clientSeedPath(hash string) string {
+	return filepath.Join(clientSeedDir(), strings.ToLower(hash)+".json")
+}
+
+// QueueClientSeed remembers an imported external download until it has seeded enough.
+// torrents added by hand, adopted ones and ones the client had before, are the user's and keep seeding
+func QueueClientSeed(td *shared.TorrentDownload) error {
+	if td.ExternalHash == "" || len(td.PlacedFiles) == 0 || !td.AddedToClient {
+		return nil
+	}
+
+	if err := shared.CreateDirectory(clientSeedDir()); err != nil {
+		return err
+	}
+
+	seed := &ClientSeed{
+		Hash:     td.ExternalHash,
+		Client:   td.Client,
+		Title:    td.FullTitle,
+		SavePath: td.SavePath,
+		Files:    td.PlacedFiles,
+		Imported: time.Now(),
+	}
+
+	data, err := json.MarshalIndent(seed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clientSeedPath(seed.Hash), data, 0644)
+}
+
+// LoadClientSeeds returns every torrent waiting to be removed from the external client
+func LoadClientSeeds() []*ClientSeed {
+	entries, err := os.ReadDir(clientSeedDir())
+	if err != nil {
+		return nil
+	}
+
+	var seeds []*ClientSeed
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(clientSeedDir(), e.Name()))
+		if err != nil {
+			logger.Log(false, "seeding: could not read %s: %v", e.Name(), err)
+			continue
+		}
+
+		seed := &ClientSeed{}
+		if err := json.Unmarshal(data, seed); err != nil {
+			logger.Log(false, "seeding: invalid job %s: %v", e.Name(), err)
+			continue
+		}
+		seeds = append(seeds, seed)
+	}
+
+	return seeds
+}
+
+func removeClientSeed(hash string) {
+	if err := os.Remove(clientSeedPath(hash)); err != nil && !os.IsNotExist(err) {
+		logger.Log(false, "seeding: could not remove job for %s: %v", hash, err)
+	}
+}
+
+// EnforceSeeding removes torrents that reached the seeding policy from the external client, with their data.
+// torrents whose library copies can't be verified are left alone.
+func EnforceSeeding(cfg shared.Config) error {
+	byClient := map[string][]*ClientSeed{}
+	for _, seed := range LoadClientSeeds() {
+		name := cfg.ClientByName(seed.Client).ClientName()
+		byClient[name] = append(byClient[name], seed)
+	}
+
+	var errs []error
+	for name, seeds := range byClient {
+		if err := enforceClientSeeding(cfg, cfg.ClientByName(seeds[0].Client), seeds); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func enforceClientSeeding(cfg shared.Config, tc shared.TorrentClientConfig, seeds []*ClientSeed) error {
+	torrentClient, err := client.NewClient(tc)
+	if err != nil {
+		return fmt.Errorf("failed to create torrent client: %w", err)
+	}
+	if torrentClient == nil {
+		return nil
+	}
+
+	torrents, err := torrentClient.ListTorrents()
+	if err != nil {
+		return fmt.Errorf("could not list torrents: %w", err)
+	}
+
+	inClient := make(map[string]client.TorrentStatus, len(torrents))
+	for _, t := range torrents {
+		inClient[strings.ToLower(t.ID)] = t
+	}
+
+	for _, seed := range seeds {
+		status, ok := inClient[strings.ToLower(seed.Hash)]
+		if !ok {
+			logger.Log(false, "seeding: %s is no longer in %s, forgetting it", seed.Title, tc.ClientName())
+			removeClientSeed(seed.Hash)
+			continue
+		}
+
+		seeded := status.SeedingTime
+		if seeded == 0 {
+			seeded = time.Since(seed.Imported)
+		}
+		if !cfg.Seeding.ClientReached(status.Uploaded, status.TotalSize, seeded) {
+			continue
+		}
+
+		files, err := torrentClient.GetTorrentFiles(seed.Hash)
+		if err != nil {
+			logger.Log(false, "seeding: could not list files of %s: %v", seed.Title, err)
+			continue
+		}
+
+		if err := verifyLibraryCopies(seed, files); err != nil {
+			logger.Log(true, "⚠️  Leaving %s in %s: %v", seed.Title, tc.ClientName(), err)
+			removeClientSeed(seed.Hash)
+			continue
+		}
+
+		if err := torrentClient.RemoveTorrent(seed.Hash, true); err != nil {
+			logger.Log(true, "⚠️  Could not remove %s from %s: %v", seed.Title, tc.ClientName(), err)
+			continue
+		}
+		removeClientSeed(seed.Hash)
+
+		ratio := 0.0
+		if status.TotalSize > 0 {
+			ratio = float64(status.Uploaded) / float64(status.TotalSize)
+		}
+		logger.Log(true, "🧹 Removed %s and its data from %s after seeding (ratio %.2f, %s)",
+			seed.Title, tc.ClientName(), ratio, seeded.Round(time.Minute))
+	}
+
+	return nil
+}
+
+// checks every downloaded video of the torrent has a library copy of its own, a copy or a hardlink.
+// symlinks would break once the torrent data is deleted.
+func verifyLibraryCopies(seed *ClientSeed, files []client.TorrentFile) error {
+	for _, f := range files {
+		if !matcher.IsVideoFile(f.Path) || f.Progress < 100 {
+			continue
+		}
+
+		placed, ok := seed.Files[f.Path]
+		if !ok {
+			return fmt.Errorf("%s was never placed in the library", f.Path)
+		}
+
+		info, err := os.Lstat(placed)
+		if err != nil {
+			return fmt.Errorf("library file %s: %w", placed, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("library file %s is a symlink, its data would be deleted", placed)
+		}
+		if info.Size() != f.Size {
+			return fmt.Errorf("library file %s is %d bytes, expected %d", placed, info.Size(), f.Size)
+		}
+	}
+
+	return nil
+}
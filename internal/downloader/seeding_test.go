@@ -0,0 +1,113 @@
+package downloader
+
+import (
+	"opforjellyfin/internal/client"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a torrent's download dir and a library, with one episode downloaded into the torrent
+func seedFixture(t *testing.T) (seed *ClientSeed, files []client.TorrentFile, library string) {
+	download, library := t.TempDir(), t.TempDir()
+
+	src := filepath.Join(download, "ep1.mkv")
+	if err := os.WriteFile(src, []byte("episode one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seed = &ClientSeed{Hash: "abc", Title: "Romance Dawn", SavePath: download, Files: map[string]string{}}
+	files = []client.TorrentFile{
+		{Index: 0, Path: "ep1.mkv", Size: 11, Progress: 100},
+		{Index: 1, Path: "ep1.nfo", Size: 3, Progress: 100},   // not a video
+		{Index: 2, Path: "ep2.mkv", Size: 20, Progress: 40.5}, // skipped, never finished
+	}
+	return seed, files, library
+}
+
+func TestVerifyLibraryCopies(t *testing.T) {
+	t.Run("hardlink", func(t *testing.T) {
+		seed, files, library := seedFixture(t)
+		placed := filepath.Join(library, "ep1.mkv")
+		if err := os.Link(filepath.Join(seed.SavePath, "ep1.mkv"), placed); err != nil {
+			t.Skipf("no hardlinks here: %v", err)
+		}
+		seed.Files["ep1.mkv"] = placed
+
+		if err := verifyLibraryCopies(seed, files); err != nil {
+			t.Errorf("a hardlinked episode should be safe to delete: %v", err)
+		}
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		seed, files, library := seedFixture(t)
+		placed := filepath.Join(library, "ep1.mkv")
+		if err := os.Symlink(filepath.Join(seed.SavePath, "ep1.mkv"), placed); err != nil {
+			t.Skipf("no symlinks here: %v", err)
+		}
+		seed.Files["ep1.mkv"] = placed
+
+		if err := verifyLibraryCopies(seed, files); err == nil || !strings.Contains(err.Error(), "symlink") {
+			t.Errorf("a symlink dies with the torrent data, got %v", err)
+		}
+	})
+
+	t.Run("missing placement", func(t *testing.T) {
+		seed, files, _ := seedFixture(t)
+
+		if err := verifyLibraryCopies(seed, files); err == nil || !strings.Contains(err.Error(), "never placed") {
+			t.Errorf("an unplaced episode should keep the torrent, got %v", err)
+		}
+	})
+
+	t.Run("placed file gone", func(t *testing.T) {
+		seed, files, library := seedFixture(t)
+		seed.Files["ep1.mkv"] = filepath.Join(library, "ep1.mkv")
+
+		if err := verifyLibraryCopies(seed, files); err == nil {
+			t.Error("a library file that is gone should keep the torrent")
+		}
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		seed, files, library := seedFixture(t)
+		placed := filepath.Join(library, "ep1.mkv")
+		if err := os.WriteFile(placed, []byte("short"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		seed.Files["ep1.mkv"] = placed
+
+		if err := verifyLibraryCopies(seed, files); err == nil || !strings.Contains(err.Error(), "expected 11") {
+			t.Errorf("a library copy of another size should keep the torrent, got %v", err)
+		}
+	})
+}
+
+func TestQueueClientSeedSkipsTorrentsWeDidNotAdd(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	td := &shared.TorrentDownload{
+		ExternalHash: "ABC",
+		FullTitle:    "Romance Dawn",
+		PlacedFiles:  map[string]string{"ep1.mkv": "/library/ep1.mkv"},
+	}
+
+	// adopted, or the client had it before
+	if err := QueueClientSeed(td); err != nil {
+		t.Fatal(err)
+	}
+	if seeds := LoadClientSeeds(); len(seeds) != 0 {
+		t.Errorf("a torrent added by hand would be removed after seeding: %+v", seeds[0])
+	}
+
+	td.AddedToClient = true
+	if err := QueueClientSeed(td); err != nil {
+		t.Fatal(err)
+	}
+	if seeds := LoadClientSeeds(); len(seeds) != 1 || seeds[0].Hash != "ABC" {
+		t.Errorf("seeds = %+v, want the torrent we added", seeds)
+	}
+}
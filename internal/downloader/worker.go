@@ -1,8 +1,15 @@
 package downloader
 
 import (
+	"context"
+	"errors"
+	"opforjellyfin/internal/client"
 	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -11,14 +18,35 @@ type Worker struct {
 	stopChan         chan struct{}
 	pollInterval     time.Duration
 	onImportCallback func()
+
+	// internal downloads run in the shared client, cancelled on Stop
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	started map[int]bool
+	running int // internal downloads in progress
+
+	// external downloads, keyed by client hash
+	stalls        map[string]*shared.StallDetector
+	imported      map[string]bool // hashes imported by this worker, not adopted again while they seed
+	lastReconcile time.Time
 }
 
+// how often the client is checked for torrents opforjellyfin doesn't track
+const reconcileInterval = 5 * time.Minute
+
 func NewWorker(cfg shared.Config, onImport func()) *Worker {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Worker{
 		cfg:              cfg,
 		stopChan:         make(chan struct{}),
 		pollInterval:     30 * time.Second,
 		onImportCallback: onImport,
+		ctx:              ctx,
+		cancel:           cancel,
+		started:          make(map[int]bool),
+		stalls:           make(map[string]*shared.StallDetector),
+		imported:         make(map[string]bool),
 	}
 }
 
@@ -28,6 +56,7 @@ func (w *Worker) Start() {
 }
 
 func (w *Worker) Stop() {
+	w.cancel()
 	close(w.stopChan)
 }
 
@@ -47,16 +76,46 @@ func (w *Worker) run() {
 }
 
 func (w *Worker) checkAndImportDownloads() {
+	external := len(w.cfg.ExternalClients()) > 0
+	if external && time.Since(w.lastReconcile) >= reconcileInterval {
+		w.lastReconcile = time.Now()
+		if _, err := AdoptTorrents(w.cfg, w.imported); err != nil {
+			logger.Log(false, "Worker: Reconcile failed: %v", err)
+		}
+	}
+
+	if external {
+		if err := EnforceSeeding(w.cfg); err != nil {
+			logger.Log(false, "Worker: Seeding check failed: %v", err)
+		}
+	}
+
 	downloads := shared.GetActiveDownloads()
 
 	if len(downloads) > 0 {
 		logger.Log(false, "Worker: Checking %d active downloads", len(downloads))
 	}
 
+	// downloads started further down this tick weren't asked about, missing from statuses says nothing about them
+	asked := map[string]bool{}
+	for _, td := range downloads {
+		if hasClientStatus(td) {
+			asked[strings.ToLower(td.ExternalHash)] = true
+		}
+	}
+
+	// one request per client for every download, fresh each tick
+	statuses, err := GetDownloadStatuses(downloads, w.cfg, 0)
+	if err != nil {
+		logger.Log(true, "Worker: Error checking download statuses: %v", err)
+	}
+
+	w.applySchedule(downloads, statuses)
+
 	hasImports := false
 	for _, td := range downloads {
 		if !td.UseExternal {
-			logger.Log(false, "Worker: Skipping internal download: %s", td.Title)
+			w.startInternalDownload(td)
 			continue
 		}
 
@@ -65,23 +124,46 @@ func (w *Worker) checkAndImportDownloads() {
 			continue
 		}
 
-		logger.Log(false, "Worker: Checking status for %s (hash: %s)", td.Title, td.ExternalHash)
-		status, err := GetDownloadStatus(td, w.cfg)
-		if err != nil {
-			logger.Log(true, "Worker: Error checking download status for %s: %v", td.Title, err)
+		if td.Pending {
+			w.startPendingExternal(td)
 			continue
 		}
 
-		if status == nil {
-			logger.Log(false, "Worker: No status returned for %s", td.Title)
+		if !td.FilesSelected {
+			w.retryFileSelection(td)
+			if td.Imported {
+				continue
+			}
+		}
+
+		status, ok := statuses[strings.ToLower(td.ExternalHash)]
+		if !ok {
+			if err == nil && asked[strings.ToLower(td.ExternalHash)] {
+				logger.Log(true, "Worker: %s (hash: %s) not found in %s", td.Title, td.ExternalHash, w.cfg.ClientFor(td).ClientName())
+			}
 			continue
 		}
 
 		logger.Log(false, "Worker: %s - Progress: %.1f%%, Complete: %v", td.Title, status.Progress, status.IsComplete)
 
 		if !status.IsComplete {
+			if client.IsBlackhole(w.cfg.ClientFor(td)) {
+				// no peers or progress to judge a stall by
+				continue
+			}
+			if status.Paused {
+				// paused on purpose, e.g added paused. no progress isn't a stall
+				delete(w.stalls, td.ExternalHash)
+				continue
+			}
+			if td.Failed() || !td.AddedToClient {
+				// gave up already, or the user's own torrent that is theirs to replace
+				continue
+			}
+			w.checkStall(td, status)
 			continue
 		}
+		delete(w.stalls, td.ExternalHash)
 
 		logger.Log(true, "Worker: Download completed, importing: %s from %s", td.Title, status.SavePath)
 
@@ -91,7 +173,15 @@ func (w *Worker) checkAndImportDownloads() {
 			shared.SaveTorrentDownload(td)
 		} else {
 			logger.Log(true, "Worker: Successfully imported: %s", td.Title)
+			w.imported[strings.ToLower(td.ExternalHash)] = true
 			hasImports = true
+
+			if client.IsBlackhole(w.cfg.ClientFor(td)) {
+				// the other client seeds it, nothing left to track
+				w.forgetBlackhole(td)
+			} else if err := QueueClientSeed(td); err != nil {
+				logger.Log(true, "Worker: Could not track seeding for %s: %v", td.Title, err)
+			}
 		}
 	}
 
@@ -105,3 +195,194 @@ func (w *Worker) checkAndImportDownloads() {
 		w.onImportCallback()
 	}
 }
+
+// downloads and places a queued internal download in the background, once
+func (w *Worker) startInternalDownload(td *shared.TorrentDownload) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if td.Done || td.Failed() || w.started[td.TorrentID] {
+		// failed ones stay failed until queued again
+		return
+	}
+
+	if reason := pendingReason(w.cfg.Schedule, w.running); reason != "" {
+		if td.PlacementProgress != "⏳ "+reason {
+			td.MarkPending(reason)
+		}
+		return
+	}
+	if err := CheckSpace(td.Release(), shared.TorrentClientConfig{}, w.cfg, busyDownloads(td)); err != nil {
+		if td.PlacementProgress != "⏳ "+waitingForSpace {
+			logger.Log(true, "Worker: %s waits: %v", td.Title, err)
+			td.MarkPending(waitingForSpace)
+		}
+		return
+	}
+	td.ClearPending()
+
+	w.started[td.TorrentID] = true
+	w.running++
+
+	logger.Log(false, "Worker: Starting internal download: %s", td.Title)
+
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			w.running--
+			w.mu.Unlock()
+		}()
+
+		err := torrent.DownloadWithFallback(w.ctx, td, w.cfg, queueTitle)
+		if errors.Is(err, torrent.ErrNothingMissing) {
+			td.Done = true
+			td.Imported = true
+			td.MarkPlaced("✅ Already have every episode")
+			return
+		}
+		if err != nil {
+			if w.ctx.Err() != nil {
+				// serve is shutting down, the partial data resumes on the next download
+				return
+			}
+			logger.Log(true, "Worker: Internal download failed for %s: %v", td.Title, err)
+			td.PlacementProgress = "❌ Failed"
+			shared.SaveTorrentDownload(td)
+
+			// queueing it again starts it anew
+			w.mu.Lock()
+			delete(w.started, td.TorrentID)
+			w.mu.Unlock()
+			return
+		}
+
+		torrent.PlaceDownload(td, w.cfg, metadata.LoadMetadataCache())
+		td.Imported = true
+		shared.SaveTorrentDownload(td)
+
+		if w.onImportCallback != nil {
+			w.onImportCallback()
+		}
+	}()
+}
+
+// pauses running external downloads when the download window closes and resumes them when it opens.
+// torrents paused by hand or added paused are left alone
+func (w *Worker) applySchedule(downloads []*shared.TorrentDownload, statuses map[string]*client.TorrentStatus) {
+	open := w.cfg.Schedule.Open(time.Now())
+
+	for _, td := range downloads {
+		if !td.UseExternal || td.Pending || td.Imported || td.Failed() || td.ExternalHash == "" || open != td.PausedBySchedule {
+			continue
+		}
+		if client.IsBlackhole(w.cfg.ClientFor(td)) {
+			// the other client downloads on its own schedule
+			continue
+		}
+		status, ok := statuses[strings.ToLower(td.ExternalHash)]
+		if !open && (!ok || status.IsComplete || status.Paused) {
+			continue
+		}
+
+		torrentClient, err := clientFor(td, w.cfg)
+		if err != nil {
+			continue
+		}
+
+		if open {
+			if err := torrentClient.ResumeTorrent(td.ExternalHash); err != nil {
+				logger.Log(false, "Worker: Could not resume %s: %v", td.Title, err)
+				continue
+			}
+			td.PausedBySchedule = false
+			td.PlacementProgress = ""
+			logger.Log(false, "Worker: Resumed %s", td.Title)
+		} else {
+			if err := torrentClient.PauseTorrent(td.ExternalHash); err != nil {
+				logger.Log(false, "Worker: Could not pause %s: %v", td.Title, err)
+				continue
+			}
+			td.PausedBySchedule = true
+			td.PlacementProgress = "⏸️ Paused outside the download window"
+			logger.Log(false, "Worker: Paused %s outside the download window", td.Title)
+		}
+		delete(w.stalls, td.ExternalHash)
+		shared.SaveTorrentDownload(td)
+	}
+}
+
+// adds a pending external download to the client once the schedule allows it
+func (w *Worker) startPendingExternal(td *shared.TorrentDownload) {
+	if reason := pendingReason(w.cfg.Schedule, runningExternal()); reason != "" {
+		if td.PlacementProgress != "⏳ "+reason {
+			td.MarkPending(reason)
+		}
+		return
+	}
+
+	if err := startExternalDownload(context.Background(), td, nil, w.cfg); errors.Is(err, ErrNoSpace) {
+		if td.PlacementProgress != "⏳ "+waitingForSpace {
+			logger.Log(true, "Worker: %s waits: %v", td.Title, err)
+			td.MarkPending(waitingForSpace)
+		}
+		return
+	} else if err != nil {
+		logger.Log(true, "Worker: Could not start %s: %v", td.Title, err)
+		return
+	}
+	logger.Log(true, "Worker: Started pending download: %s (hash: %s, client: %s)", td.Title, td.ExternalHash, td.Client)
+}
+
+// switches a stalled external download to the next best release, or gives up after the configured attempts
+func (w *Worker) checkStall(td *shared.TorrentDownload, status *client.TorrentStatus) {
+	now := time.Now()
+
+	detector, ok := w.stalls[td.ExternalHash]
+	if !ok {
+		detector = shared.NewStallDetector(w.cfg.Retry, now)
+		w.stalls[td.ExternalHash] = detector
+	}
+	detector.Observe(now, status.Downloaded, status.Seeders+status.Peers)
+
+	if !detector.Stalled(now) {
+		return
+	}
+	delete(w.stalls, td.ExternalHash)
+
+	logger.Log(true, "Worker: %s stalled at %.1f%%", td.Title, status.Progress)
+	if err := shared.BlockRelease(td.TorrentID, td.FullTitle, "stalled"); err != nil {
+		logger.Log(false, "Worker: Could not blocklist %s: %v", td.FullTitle, err)
+	}
+
+	if err := switchExternalRelease(td, w.cfg); err != nil {
+		logger.Log(true, "Worker: Giving up on %s: %v", td.Title, err)
+		td.PlacementProgress = "❌ Stalled - no seeders?"
+		shared.SaveTorrentDownload(td)
+	}
+}
+
+// file lists are only known once the client fetched the torrent, so selection may need another try
+func (w *Worker) retryFileSelection(td *shared.TorrentDownload) {
+	torrentClient, err := clientFor(td, w.cfg)
+	if err != nil {
+		logger.Log(false, "Worker: %v", err)
+		return
+	}
+
+	if err := selectExternalFiles(torrentClient, td, w.cfg); err != nil {
+		logger.Log(false, "Worker: Could not select files for %s: %v", td.Title, err)
+		return
+	}
+	shared.SaveTorrentDownload(td)
+}
+
+func (w *Worker) forgetBlackhole(td *shared.TorrentDownload) {
+	torrentClient, err := clientFor(td, w.cfg)
+	if err != nil {
+		logger.Log(false, "Worker: %v", err)
+		return
+	}
+	if err := torrentClient.RemoveTorrent(td.ExternalHash, false); err != nil {
+		logger.Log(false, "Worker: Could not forget %s: %v", td.Title, err)
+	}
+}
@@ -3,6 +3,7 @@ package matcher
 
 import (
 	"fmt"
+	"math"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
 	"opforjellyfin/internal/ui"
@@ -11,24 +12,25 @@ import (
 	"strings"
 )
 
-// Matches video-file to metadata, then places it
-// No mutex needed here - shared.SafeMoveFile handles all locking
-func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataIndex, ogcr string) (string, error) {
+// Matches video-file to metadata, then places it in a library
+// No mutex needed here - shared.PlaceFile handles all locking
+// returns the display message and the path the video was placed at
+func MatchAndPlaceVideo(videoPath string, lib shared.LibraryConfig, index *shared.MetadataIndex, ogcr string) (string, string, error) {
 
 	if _, err := os.Stat(videoPath); os.IsNotExist(err) {
 		logger.Log(true, "   ❌ Video file does not exist: %s", videoPath)
-		return "", nil
+		return "", "", nil
 	}
 
 	fileName := filepath.Base(videoPath)
 	logger.Log(false, "   🔍 Attempting to match: %s (chapter range: %s)", fileName, ogcr)
 
 	// strict
-	dstPathNoSuffix := findMetadataMatch(fileName, index, ogcr)
+	dstPathNoSuffix := findMetadataMatch(fileName, index, ogcr, lib.TargetDir)
 
 	if dstPathNoSuffix == "" {
 		logger.Log(true, "   ❌ No metadata match found for: %s", fileName)
-		return "", fmt.Errorf("no metadata match found for file: %s (chapter range: %s)", fileName, ogcr)
+		return "", "", fmt.Errorf("no metadata match found for file: %s (chapter range: %s)", fileName, ogcr)
 	}
 
 	logger.Log(false, "   📍 Target path (no ext): %s", dstPathNoSuffix)
@@ -37,14 +39,14 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 	ext := filepath.Ext(fileName)
 	finalPath := dstPathNoSuffix + ext
 
-	// SafeMoveFile now handles all locking internally
-	if err := shared.SafeMoveFile(videoPath, finalPath); err != nil {
+	// PlaceFile handles all locking internally
+	if err := shared.PlaceFile(videoPath, finalPath, lib.Placement); err != nil {
 		logger.Log(true, "   ❌ Failed to place file to target location: %s", err)
-		return "", fmt.Errorf("failed to place %s to %s: %w", fileName, finalPath, err)
+		return "", "", fmt.Errorf("failed to place %s to %s: %w", fileName, finalPath, err)
 	}
 
 	//relative path for logs
-	relPath, _ := filepath.Rel(defaultDir, finalPath)
+	relPath, _ := filepath.Rel(lib.TargetDir, finalPath)
 	//debug
 	logger.Log(false, "%s", fmt.Sprintf("placed: %s → %s", fileName, relPath))
 
@@ -61,19 +63,16 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 	outRelPath := ui.AnsiPadRight(".."+relPathNoPrefix, 36, "..")
 	msg := fmt.Sprintf("🎞️  Placed: %s → %s", outFileName, outRelPath)
 
-	return msg, nil
+	return msg, finalPath, nil
 }
 
 // returns directory to place file, without suffix
 // Returns empty string if no match found
-func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr string) string {
-
-	cfg := shared.LoadConfig()
-	baseDir := cfg.TargetDir
+func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr, baseDir string) string {
 
 	// finds season containing chapterRange, returns the seasonFolderName and seasonIndex
 	// uses ogcr to find correct season even if its a bundle
-	seasonFolderName, seasonIndex := findSeasonForChapter(ogcr, index)
+	seasonFolderName := findSeasonForChapter(ogcr, index)
 	if seasonFolderName == "" {
 		logger.Log(true, "   ❌ findMetaDataMatch: failed to find Season-folder for range %s", ogcr)
 		return ""
@@ -81,7 +80,7 @@ func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr string
 	logger.Log(false, "   ✓ Season found: %s for range %s", seasonFolderName, ogcr)
 
 	// searches the seasonIndex for matching title for chapterRange, tries ogcr first for single-episode seasons
-	newFileName := findTitleForChapter(ogcr, seasonIndex)
+	newFileName := findTitleForChapter(ogcr, seasonFolderName, index)
 	if newFileName == "" {
 		// if first fails, extract specific chapterRange from fileName
 		chapterRange := shared.ExtractChapterRangeFromTitle(fileName)
@@ -97,15 +96,15 @@ func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr string
 			logger.Log(false, "   → Rough extracted chapterNum: %s", chapterNum)
 
 			if isRange {
-				newFileName = findTitleForChapter(chapterNum, seasonIndex)
+				newFileName = findTitleForChapter(chapterNum, seasonFolderName, index)
 			} else {
 				// build a matching string from season and rough chapter, eg: seasonNum = 3 and chapternum = 05 => S03E05
 				epKey := fmt.Sprintf("S%sE%s", seasonNum, chapterNum)
-				newFileName = findTitleRough(epKey, seasonIndex)
+				newFileName = findTitleRough(epKey, seasonFolderName, index)
 			}
 		} else {
 			// if extraction succeeded, find title from chapterRange
-			newFileName = findTitleForChapter(chapterRange, seasonIndex)
+			newFileName = findTitleForChapter(chapterRange, seasonFolderName, index)
 		}
 	} else {
 		logger.Log(false, "   ✓ Title match found: ChapterKey: %s - EpisodeTitle: %s", ogcr, newFileName)
@@ -124,41 +123,38 @@ func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr string
 }
 
 // exact match, returns title from metadataindex using chapterKey.
-func findTitleForChapter(chapterKey string, sindex shared.SeasonIndex) string {
+func findTitleForChapter(chapterKey, seasonKey string, index *shared.MetadataIndex) string {
 	normKey := shared.NormalizeDash(chapterKey)
 
 	logger.Log(false, "findEpisodeKeyForChapter: chapterKey: %s - normKey: %s ", chapterKey, normKey)
 
-	for epRange, ep := range sindex.EpisodeRange {
-		if shared.NormalizeDash(epRange) == normKey {
-			return ep.Title
-		}
+	if ep, ok := index.Chapters.EpisodeExact(normKey, seasonKey); ok {
+		return ep.Title
 	}
 
 	// no title found based on ChapterKey,
 	return ""
 }
 
-// finds the season a ChapterKey belongs to. returns the season name as a string, also returns the whole SeasonIndex struct
-func findSeasonForChapter(chapterKey string, index *shared.MetadataIndex) (string, shared.SeasonIndex) {
-	chStart, chEnd := shared.ParseRange(chapterKey)
-
-	for seasonName, season := range index.Seasons {
-		seasonStart, seasonEnd := shared.ParseRange(season.Range)
-
-		if chStart >= seasonStart && chEnd <= seasonEnd {
-			return seasonName, season
-		}
+// finds the season a ChapterKey belongs to. returns the season name as a string
+func findSeasonForChapter(chapterKey string, index *shared.MetadataIndex) string {
+	chStart, chEnd := shared.ParseRange(shared.NormalizeDash(chapterKey))
+	if chStart < 0 {
+		return ""
 	}
 
-	return "", shared.SeasonIndex{}
+	season, ok := index.Chapters.SeasonFor(chStart, chEnd)
+	if !ok {
+		return ""
+	}
 
+	return season.SeasonKey
 }
 
 // rough finder
-func findTitleRough(epKey string, sindex shared.SeasonIndex) string {
+func findTitleRough(epKey, seasonKey string, index *shared.MetadataIndex) string {
 
-	for _, ep := range sindex.EpisodeRange {
+	for _, ep := range index.Chapters.EpisodesIn(seasonKey, 0, math.MaxInt) {
 		if strings.Contains(ep.Title, epKey) {
 			logger.Log(false, "roughFindTitle match found: %s > %s", epKey, ep.Title)
 			return ep.Title
@@ -6,15 +6,21 @@ import (
 	"opforjellyfin/internal/shared"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
 )
 
-// walks through downloaded files and tries to place them in correct dir
-func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, index *shared.MetadataIndex) {
+// walks through downloaded files and tries to place them in correct dir of every library
+func ProcessTorrentFiles(tmpDir string, libs []shared.LibraryConfig, td *shared.TorrentDownload, index *shared.MetadataIndex) {
 	filesChecked := 0
 	filesPlaced := 0
 	var lastError error
 
+	if len(libs) == 0 {
+		logger.Log(true, "⚠️  No library accepts %s (%s)", td.Title, td.Quality)
+		td.MarkPlaced(fmt.Sprintf("⚠️ No library accepts %s!", td.Quality))
+		return
+	}
+
 	// collect all paths
 	td.PlacementProgress = fmt.Sprintf("🔧 Finding files to place in %s", tmpDir)
 	logger.Log(true, "🔍 Scanning directory for video files: %s", tmpDir)
@@ -30,12 +36,20 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(info.Name()))
-		if ext != ".mkv" && ext != ".mp4" {
+		if !IsVideoFile(info.Name()) {
 			logger.Log(false, "   ⏭️  Skipping non-video file: %s", info.Name())
 			return nil
 		}
 
+		// files left out of a selective download may exist partially, never place them
+		if td.SelectedFiles != nil {
+			rel, _ := filepath.Rel(tmpDir, path)
+			if !slices.Contains(td.SelectedFiles, filepath.ToSlash(rel)) {
+				logger.Log(false, "   ⏭️  Skipping unselected file: %s", info.Name())
+				return nil
+			}
+		}
+
 		logger.Log(true, "   ✅ Found video file: %s (%.2f MB)", info.Name(), float64(info.Size())/(1024*1024))
 		vidPaths = append(vidPaths, path)
 		return nil
@@ -73,19 +87,32 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 		td.PlacementProgress = fmt.Sprintf("🔧 Placing ➝ %d/%d - %s", i+1, len(vidPaths), readablePath)
 		shared.SaveTorrentDownload(td)
 
-		// match and place
-		msg, err := MatchAndPlaceVideo(path, outDir, index, td.ChapterRange)
-		if err != nil {
-			logger.Log(true, "   ❌ Error placing %s: %v", fileName, err)
-			lastError = err
-		} else if msg != "" {
+		// match and place, once per library
+		placed := false
+		for _, lib := range libs {
+			msg, placedPath, err := MatchAndPlaceVideo(path, lib, index, td.ChapterRange)
+			if err != nil {
+				logger.Log(true, "   ❌ Error placing %s in %s: %v", fileName, lib.Name, err)
+				lastError = err
+			} else if msg != "" {
+				if !placed {
+					recordPlacedFile(td, tmpDir, path, placedPath)
+				}
+				placed = true
+				if len(libs) > 1 {
+					msg = fmt.Sprintf("[%s] %s", lib.Name, msg)
+				}
+				//save msg for final summary
+				td.PlacementFull = append(td.PlacementFull, msg)
+				shared.SaveTorrentDownload(td)
+			} else {
+				logger.Log(true, "   ⚠️  No message returned for %s - file may not have been placed", fileName)
+			}
+		}
+
+		if placed {
 			filesPlaced++
 			logger.Log(true, "   ✅ Successfully placed file %d/%d", filesPlaced, len(vidPaths))
-			//save msg for final summary
-			td.PlacementFull = append(td.PlacementFull, msg)
-			shared.SaveTorrentDownload(td)
-		} else {
-			logger.Log(true, "   ⚠️  No message returned for %s - file may not have been placed", fileName)
 		}
 	}
 
@@ -116,3 +143,15 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 
 	td.MarkPlaced(placedMsg)
 }
+
+// remembers where a torrent file ended up, keyed by its path inside the torrent. used by the seeder.
+func recordPlacedFile(td *shared.TorrentDownload, tmpDir, path, placedPath string) {
+	rel, err := filepath.Rel(tmpDir, path)
+	if err != nil || placedPath == "" {
+		return
+	}
+	if td.PlacedFiles == nil {
+		td.PlacedFiles = make(map[string]string)
+	}
+	td.PlacedFiles[filepath.ToSlash(rel)] = placedPath
+}
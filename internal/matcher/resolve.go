@@ -0,0 +1,59 @@
+package matcher
+
+import (
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"path"
+	"strings"
+)
+
+// IsVideoFile reports whether a file is a video we place
+func IsVideoFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".mkv" || ext == ".mp4"
+}
+
+// ResolveTarget returns where a torrent file would be placed in baseDir, without extension. Empty if no episode matches.
+func ResolveTarget(fileName string, index *shared.MetadataIndex, chapterRange, baseDir string) string {
+	return findMetadataMatch(fileName, index, chapterRange, baseDir)
+}
+
+// SelectMissingFiles returns which torrent files are worth downloading: videos for an episode that is missing in at least one library.
+// paths are torrent file paths joined by '/'. If no video matches an episode, every video is selected, so a matcher miss never loses a download.
+func SelectMissingFiles(paths []string, libs []shared.LibraryConfig, index *shared.MetadataIndex, chapterRange string) []bool {
+	wanted := make([]bool, len(paths))
+	anyMatched := false
+
+	for i, p := range paths {
+		if !IsVideoFile(p) {
+			continue
+		}
+
+		for _, lib := range libs {
+			target := ResolveTarget(path.Base(p), index, chapterRange, lib.TargetDir)
+			if target == "" {
+				continue
+			}
+			anyMatched = true
+
+			if !haveVideo(target) {
+				wanted[i] = true
+				break
+			}
+		}
+	}
+
+	if !anyMatched {
+		logger.Log(false, "No file matched an episode for %s, selecting all videos", chapterRange)
+		for i, p := range paths {
+			wanted[i] = IsVideoFile(p)
+		}
+	}
+
+	return wanted
+}
+
+// video exists for a target path without extension
+func haveVideo(target string) bool {
+	return shared.FileExists(target+".mkv") || shared.FileExists(target+".mp4")
+}
@@ -0,0 +1,208 @@
+package metadata
+
+import (
+	"fmt"
+	"io"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// user supplied artwork lives here, mirroring the library layout. Jellyfin skips hidden folders.
+const customArtworkDir = ".artwork"
+
+// file names Jellyfin accepts for each kind of artwork, first one is used when saving
+var artworkNames = map[string][]string{
+	"poster": {"poster", "folder", "cover"},
+	"fanart": {"fanart", "backdrop", "background"},
+	"banner": {"banner"},
+}
+
+var artworkExts = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// IsArtworkKind reports whether kind is poster, fanart or banner
+func IsArtworkKind(kind string) bool {
+	_, ok := artworkNames[kind]
+	return ok
+}
+
+// IsArtworkExt reports whether ext is a supported image extension
+func IsArtworkExt(ext string) bool {
+	for _, e := range artworkExts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArtworkPath returns the absolute path for a kind of artwork for a season, or the show if seasonKey is empty
+func ArtworkPath(baseDir string, index *shared.MetadataIndex, seasonKey, kind string) (string, bool) {
+	art := index.Artwork
+	if seasonKey != "" {
+		season, exists := index.Seasons[seasonKey]
+		if !exists {
+			return "", false
+		}
+		art = season.Artwork
+	}
+
+	rel := artworkField(art, kind)
+	if rel == "" {
+		return "", false
+	}
+
+	return filepath.Join(baseDir, rel), true
+}
+
+// SaveCustomArtwork stores user supplied artwork in the primary library and puts it where Jellyfin looks for it, in every library
+func SaveCustomArtwork(cfg shared.Config, seasonKey, kind, ext string, r io.Reader) error {
+	baseDir := cfg.TargetDir
+
+	if !IsArtworkKind(kind) || !IsArtworkExt(ext) {
+		return fmt.Errorf("unsupported artwork: %s%s", kind, ext)
+	}
+
+	dir := filepath.Join(baseDir, customArtworkDir, seasonKey)
+	if err := shared.CreateDirectory(dir); err != nil {
+		return fmt.Errorf("could not create artwork dir: %w", err)
+	}
+
+	// only keep one custom file per kind
+	removeArtworkVariants(dir, kind)
+
+	dst := filepath.Join(dir, kind+strings.ToLower(ext))
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("could not save artwork: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("could not save artwork: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not save artwork: %w", err)
+	}
+
+	for _, libDir := range cfg.LibraryDirs(baseDir) {
+		if err := applyCustomArtwork(baseDir, libDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copies custom artwork from baseDir over the synced artwork in dstDir. runs after every sync, like the nfo overrides.
+func applyCustomArtwork(baseDir, dstDir string) error {
+	root := filepath.Join(baseDir, customArtworkDir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(d.Name())
+		kind := strings.TrimSuffix(d.Name(), ext)
+		if !IsArtworkKind(kind) || !IsArtworkExt(ext) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return nil
+		}
+		targetDir := filepath.Join(dstDir, rel)
+
+		// upstream may ship the same kind with a different name or extension
+		removeArtworkVariants(targetDir, kind)
+
+		if err := shared.CopyFile(path, filepath.Join(targetDir, artworkNames[kind][0]+strings.ToLower(ext)), 0644); err != nil {
+			return fmt.Errorf("could not place custom artwork %s: %w", path, err)
+		}
+
+		logger.Log(false, "artwork: placed custom %s for %s", kind, rel)
+		return nil
+	})
+}
+
+// fills in artwork for the show and every season
+func detectArtwork(index *shared.MetadataIndex, baseDir string) {
+	index.Artwork = findArtwork(baseDir, baseDir, "")
+
+	for seasonKey, season := range index.Seasons {
+		prefix := fmt.Sprintf("season%02d-", season.SeasonNumber)
+		if seasonKey == "Specials" {
+			prefix = "season-specials-"
+		}
+
+		art := findArtwork(baseDir, filepath.Join(baseDir, seasonKey), "")
+
+		// Jellyfin also accepts season art in the show folder, e.g season02-poster.jpg
+		fallback := findArtwork(baseDir, baseDir, prefix)
+		if art.Poster == "" {
+			art.Poster = fallback.Poster
+		}
+		if art.Fanart == "" {
+			art.Fanart = fallback.Fanart
+		}
+		if art.Banner == "" {
+			art.Banner = fallback.Banner
+		}
+
+		season.Artwork = art
+		index.Seasons[seasonKey] = season
+	}
+}
+
+// looks for artwork in dir, with an optional file name prefix. paths are relative to baseDir
+func findArtwork(baseDir, dir, prefix string) shared.Artwork {
+	find := func(kind string) string {
+		for _, name := range artworkNames[kind] {
+			for _, ext := range artworkExts {
+				path := filepath.Join(dir, prefix+name+ext)
+				if shared.FileExists(path) {
+					rel, _ := filepath.Rel(baseDir, path)
+					return rel
+				}
+			}
+		}
+		return ""
+	}
+
+	return shared.Artwork{
+		Poster: find("poster"),
+		Fanart: find("fanart"),
+		Banner: find("banner"),
+	}
+}
+
+func removeArtworkVariants(dir, kind string) {
+	for _, name := range artworkNames[kind] {
+		for _, ext := range artworkExts {
+			path := filepath.Join(dir, name+ext)
+			if shared.FileExists(path) {
+				if err := os.Remove(path); err != nil {
+					logger.Log(false, "artwork: could not remove %s: %v", path, err)
+				}
+			}
+		}
+	}
+}
+
+func artworkField(art shared.Artwork, kind string) string {
+	switch kind {
+	case "poster":
+		return art.Poster
+	case "fanart":
+		return art.Fanart
+	case "banner":
+		return art.Banner
+	}
+	return ""
+}
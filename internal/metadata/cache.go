@@ -22,9 +22,14 @@ func LoadMetadataCache() *shared.MetadataIndex {
 		data, err := os.ReadFile(filepath.Join(cfg.TargetDir, "metadata-index.json"))
 		if err != nil {
 			metadataCache = &shared.MetadataIndex{}
+			metadataCache.Chapters = shared.BuildChapterIndex(metadataCache)
 			return
 		}
 		json.Unmarshal(data, &metadataCache)
+		if metadataCache == nil {
+			metadataCache = &shared.MetadataIndex{}
+		}
+		metadataCache.Chapters = shared.BuildChapterIndex(metadataCache)
 	})
 
 	return metadataCache
@@ -17,6 +17,9 @@ func buildIndexFromDir(baseDir string) (*shared.MetadataIndex, error) {
 		Seasons: make(map[string]shared.SeasonIndex),
 	}
 
+	// local fixes win over upstream metadata
+	overrides := loadOverridesOrEmpty(baseDir)
+
 	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() || !shared.IsEpisodeNFO(d.Name()) {
 			return nil
@@ -28,6 +31,9 @@ func buildIndexFromDir(baseDir string) (*shared.MetadataIndex, error) {
 		}
 
 		season, episode, chapterRange := extractEpisodeMetadata(data)
+		if ov, ok := overrides.Episodes[strings.TrimSuffix(d.Name(), ".nfo")]; ok && ov.ChapterRange != "" {
+			chapterRange = ov.ChapterRange
+		}
 		if season == "" || episode == "" || chapterRange == "" {
 			logger.Log(false, "indexbuilder: missed param for %s - season: %s - episode %s - chapterRange %s", d.Name(), season, episode, chapterRange)
 			return nil
@@ -66,6 +72,11 @@ func buildIndexFromDir(baseDir string) (*shared.MetadataIndex, error) {
 	// put range on season
 	calculateSeasonRanges(index)
 	nameSeasons(index, baseDir)
+	applySeasonOverrides(index, overrides)
+	detectArtwork(index, baseDir)
+
+	// interval lookup for chapter -> season/episode
+	index.Chapters = shared.BuildChapterIndex(index)
 
 	return index, nil
 }
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"opforjellyfin/internal/shared"
+	"path/filepath"
+)
+
+// ChapterLookup describes where a chapter ends up in the library
+type ChapterLookup struct {
+	Arc          string `json:"arc"`
+	SeasonKey    string `json:"seasonKey"`
+	SeasonNumber int    `json:"seasonNumber"`
+	Episode      string `json:"episode"`
+	ChapterRange string `json:"chapterRange"`
+	Path         string `json:"path"`
+	HaveVideo    bool   `json:"haveVideo"`
+}
+
+// LookupChapters returns every episode covering chapters start-end, in chapter order.
+// Path is the video if we have it, otherwise the episode .nfo.
+// If no episode covers the range, the covering seasons are returned without episode info.
+func LookupChapters(start, end int) []ChapterLookup {
+	index := LoadMetadataCache()
+	cfg := shared.LoadConfig()
+
+	var results []ChapterLookup
+
+	for _, ep := range index.Chapters.Episodes(start, end) {
+		path := EpisodeVideoPath(cfg.TargetDir, ep)
+		haveVideo := path != ""
+		if !haveVideo {
+			path = filepath.Join(cfg.TargetDir, ep.SeasonKey, ep.Title+".nfo")
+		}
+
+		results = append(results, ChapterLookup{
+			Arc:          index.Seasons[ep.SeasonKey].Name,
+			SeasonKey:    ep.SeasonKey,
+			SeasonNumber: ep.SeasonNumber,
+			Episode:      ep.Title,
+			ChapterRange: ep.ChapterRange,
+			Path:         path,
+			HaveVideo:    haveVideo,
+		})
+	}
+
+	if len(results) > 0 {
+		return results
+	}
+
+	for _, s := range index.Chapters.Seasons(start, end) {
+		results = append(results, ChapterLookup{
+			Arc:          index.Seasons[s.SeasonKey].Name,
+			SeasonKey:    s.SeasonKey,
+			SeasonNumber: s.SeasonNumber,
+			ChapterRange: s.ChapterRange,
+			Path:         filepath.Join(cfg.TargetDir, s.SeasonKey),
+		})
+	}
+
+	return results
+}
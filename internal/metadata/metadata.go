@@ -51,17 +51,32 @@ func cloneAndCopyRepo(baseDir string, cfg shared.Config, syncOnly bool) error {
 	}
 
 	srcDir := filepath.Join(tmpDir, "One Pace")
-	var err error
 
-	if syncOnly {
-		err = shared.SyncDir(srcDir, baseDir)
-	} else {
-		err = shared.CopyDir(srcDir, baseDir)
-	}
-
-	if err != nil {
-		spinner.Stop()
-		return fmt.Errorf("failed to copy metadata: %w", err)
+	// overrides and custom artwork are kept in the primary library and applied to all of them
+	overrides := loadOverridesOrEmpty(baseDir)
+
+	for _, dir := range cfg.LibraryDirs(baseDir) {
+		var err error
+		if syncOnly {
+			err = shared.SyncDir(srcDir, dir)
+		} else {
+			err = shared.CopyDir(srcDir, dir)
+		}
+
+		if err != nil {
+			spinner.Stop()
+			return fmt.Errorf("failed to copy metadata to %s: %w", dir, err)
+		}
+
+		// upstream just overwrote any local edits, put the overrides back
+		if err := applyOverridesToNFOs(dir, overrides); err != nil {
+			spinner.Stop()
+			return fmt.Errorf("failed to apply metadata overrides: %w", err)
+		}
+		if err := applyCustomArtwork(baseDir, dir); err != nil {
+			spinner.Stop()
+			return fmt.Errorf("failed to apply custom artwork: %w", err)
+		}
 	}
 
 	if err := BuildMetadataIndex(baseDir); err != nil {
@@ -0,0 +1,183 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const overridesFile = "metadata-overrides.json"
+
+var (
+	reNFOTitle       = regexp.MustCompile(`(?s)<title>.*?</title>`)
+	reNFOChapterLine = regexp.MustCompile(`(?i)(Manga\s*Chapter\(s\)?:\s*)\d+(?:\s*[-–—]\s*\d+)?(?:\s*,\s*\d+(?:\s*[-–—]\s*\d+)?)*`)
+	reNFONamedSeason = regexp.MustCompile(`<namedseason\s+number="0*(\d+)">[^<]*</namedseason>`)
+)
+
+// LoadOverrides reads metadata-overrides.json from baseDir. A missing file means no overrides.
+func LoadOverrides(baseDir string) (*shared.MetadataOverrides, error) {
+	overrides := &shared.MetadataOverrides{}
+
+	data, err := os.ReadFile(filepath.Join(baseDir, overridesFile))
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	if err != nil {
+		return overrides, fmt.Errorf("could not read overrides: %w", err)
+	}
+
+	if err := json.Unmarshal(data, overrides); err != nil {
+		return &shared.MetadataOverrides{}, fmt.Errorf("invalid overrides format: %w", err)
+	}
+
+	return overrides, nil
+}
+
+// loads overrides for the index builder and nfo sync, logs and ignores broken files
+func loadOverridesOrEmpty(baseDir string) *shared.MetadataOverrides {
+	overrides, err := LoadOverrides(baseDir)
+	if err != nil {
+		logger.Log(true, "⚠️  Ignoring %s: %v", overridesFile, err)
+	}
+	return overrides
+}
+
+// applies season overrides to an index. episode overrides are applied while the index is built.
+func applySeasonOverrides(index *shared.MetadataIndex, overrides *shared.MetadataOverrides) {
+	for seasonKey, ov := range overrides.Seasons {
+		season, exists := index.Seasons[seasonKey]
+		if !exists {
+			logger.Log(false, "overrides: no season %s in index", seasonKey)
+			continue
+		}
+		if ov.Name != "" {
+			season.Name = ov.Name
+		}
+		index.Seasons[seasonKey] = season
+	}
+}
+
+// writes overrides into the synced .nfo files, so they survive SyncDir/CopyDir and Jellyfin shows them
+func applyOverridesToNFOs(baseDir string, overrides *shared.MetadataOverrides) error {
+	if len(overrides.Episodes) == 0 && len(overrides.Seasons) == 0 {
+		return nil
+	}
+
+	applied := 0
+
+	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !shared.IsEpisodeNFO(d.Name()) {
+			return nil
+		}
+
+		ov, ok := overrides.Episodes[strings.TrimSuffix(d.Name(), ".nfo")]
+		if !ok {
+			return nil
+		}
+
+		changed, err := rewriteFile(path, func(data string) string {
+			if ov.ChapterRange != "" {
+				data = reNFOChapterLine.ReplaceAllString(data, "${1}"+ov.ChapterRange)
+			}
+			if ov.Title != "" {
+				data = replaceFirst(reNFOTitle, data, "<title>"+escapeXML(ov.Title)+"</title>")
+			}
+			return data
+		})
+		if err != nil {
+			return err
+		}
+		if changed {
+			applied++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for seasonKey, ov := range overrides.Seasons {
+		if ov.Name == "" {
+			continue
+		}
+
+		seasonNFO := filepath.Join(baseDir, seasonKey, "season.nfo")
+		if shared.FileExists(seasonNFO) {
+			changed, err := rewriteFile(seasonNFO, func(data string) string {
+				return replaceFirst(reNFOTitle, data, "<title>"+escapeXML(ov.Name)+"</title>")
+			})
+			if err != nil {
+				return err
+			}
+			if changed {
+				applied++
+			}
+		}
+
+		snum, err := strconv.Atoi(shared.ExtractSeasonNumber(seasonKey))
+		if err != nil || snum == 0 {
+			continue
+		}
+
+		changed, err := rewriteFile(filepath.Join(baseDir, "tvshow.nfo"), func(data string) string {
+			return reNFONamedSeason.ReplaceAllStringFunc(data, func(tag string) string {
+				if n, _ := strconv.Atoi(reNFONamedSeason.FindStringSubmatch(tag)[1]); n != snum {
+					return tag
+				}
+				return fmt.Sprintf(`<namedseason number="%d">%d. %s</namedseason>`, snum, snum, escapeXML(ov.Name))
+			})
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if changed {
+			applied++
+		}
+	}
+
+	logger.Log(false, "overrides: applied to %d nfo file(s)", applied)
+	return nil
+}
+
+// rewrites a file in place if edit changed it
+func rewriteFile(path string, edit func(string) string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	updated := edit(string(data))
+	if updated == string(data) {
+		return false, nil
+	}
+
+	if err := os.WriteFile(path, []byte(updated), info.Mode()); err != nil {
+		return false, fmt.Errorf("could not write %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+func replaceFirst(re *regexp.Regexp, s, repl string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	return s[:loc[0]] + repl + s[loc[1]:]
+}
+
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
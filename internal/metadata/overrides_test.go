@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeNFO(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readNFO(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyOverridesToNFOs(t *testing.T) {
+	base := t.TempDir()
+
+	writeNFO(t, filepath.Join(base, overridesFile), `{
+  "seasons": {"Season 2": {"name": "Orange Town & Syrup"}},
+  "episodes": {"S02E01": {"chapter_range": "8-9", "title": "Buggy <the Clown>"}}
+}`)
+	writeNFO(t, filepath.Join(base, "tvshow.nfo"), `<tvshow>
+  <namedseason number="01">1. Romance Dawn</namedseason>
+  <namedseason number="02">2. Orange Town</namedseason>
+  <namedseason number="12">12. Alabasta</namedseason>
+</tvshow>`)
+	writeNFO(t, filepath.Join(base, "Season 2", "season.nfo"), `<season><title>Orange Town</title></season>`)
+	writeNFO(t, filepath.Join(base, "Season 2", "S02E01.nfo"), `<episodedetails>
+  <title>Old title</title>
+  <plot>Manga Chapter(s): 8-11</plot>
+</episodedetails>`)
+	untouched := `<episodedetails><title>Second</title><plot>Manga Chapter(s): 12-15</plot></episodedetails>`
+	writeNFO(t, filepath.Join(base, "Season 2", "S02E02.nfo"), untouched)
+
+	overrides, err := LoadOverrides(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyOverridesToNFOs(base, overrides); err != nil {
+		t.Fatal(err)
+	}
+
+	tvshow := readNFO(t, filepath.Join(base, "tvshow.nfo"))
+	for _, want := range []string{
+		`<namedseason number="01">1. Romance Dawn</namedseason>`,
+		`<namedseason number="2">2. Orange Town &amp; Syrup</namedseason>`,
+		`<namedseason number="12">12. Alabasta</namedseason>`,
+	} {
+		if !strings.Contains(tvshow, want) {
+			t.Errorf("tvshow.nfo is missing %s:\n%s", want, tvshow)
+		}
+	}
+
+	if got := readNFO(t, filepath.Join(base, "Season 2", "season.nfo")); got != `<season><title>Orange Town &amp; Syrup</title></season>` {
+		t.Errorf("season.nfo = %s", got)
+	}
+
+	episode := readNFO(t, filepath.Join(base, "Season 2", "S02E01.nfo"))
+	if !strings.Contains(episode, "<title>Buggy &lt;the Clown&gt;</title>") || !strings.Contains(episode, "Manga Chapter(s): 8-9</plot>") {
+		t.Errorf("S02E01.nfo not rewritten:\n%s", episode)
+	}
+	if got := readNFO(t, filepath.Join(base, "Season 2", "S02E02.nfo")); got != untouched {
+		t.Errorf("an episode without overrides changed:\n%s", got)
+	}
+
+	// running again after a sync changes nothing more
+	if err := applyOverridesToNFOs(base, overrides); err != nil {
+		t.Fatal(err)
+	}
+	if again := readNFO(t, filepath.Join(base, "tvshow.nfo")); again != tvshow {
+		t.Errorf("tvshow.nfo changed on the second run:\n%s", again)
+	}
+	if again := readNFO(t, filepath.Join(base, "Season 2", "S02E01.nfo")); again != episode {
+		t.Errorf("S02E01.nfo changed on the second run:\n%s", again)
+	}
+}
@@ -18,37 +18,47 @@ func HaveVideoStatus(chapterRange string) int {
 	cfg := shared.LoadConfig()
 	baseDir := cfg.TargetDir
 
-	for seasonKey, season := range index.Seasons {
-		seasonDir := filepath.Join(baseDir, seasonKey)
-
-		if season.Range == chapterRange {
-			v, n := CountVideosAndTotal(seasonDir)
-			logger.Log(false, "HaveVideoStatus: counted %d videos and %d nfos for seasonKey: %s", v, n, seasonKey)
-			if v == 0 {
-				return 0
-			}
+	if season, ok := index.Chapters.SeasonExact(chapterRange); ok {
+		seasonDir := filepath.Join(baseDir, season.SeasonKey)
+		v, n := CountVideosAndTotal(seasonDir)
+		logger.Log(false, "HaveVideoStatus: counted %d videos and %d nfos for seasonKey: %s", v, n, season.SeasonKey)
+		if v == 0 {
+			return 0
+		}
 
-			if v < n {
-				return 1
-			}
+		if v < n {
+			return 1
+		}
+
+		return 2
+	}
 
+	if ep, ok := index.Chapters.EpisodeExact(chapterRange, ""); ok {
+		if HaveEpisodeVideo(baseDir, ep) {
 			return 2
 		}
+	}
+
+	return 0
+}
 
-		for epRange, epData := range season.EpisodeRange {
-			if epRange == chapterRange {
-				videoPathMP4 := filepath.Join(seasonDir, epData.Title+".mp4")
-				videoPathMKV := filepath.Join(seasonDir, epData.Title+".mkv")
+// HaveEpisodeVideo checks if a video exists for an indexed episode
+func HaveEpisodeVideo(baseDir string, ep shared.ChapterInterval) bool {
+	return EpisodeVideoPath(baseDir, ep) != ""
+}
 
-				if shared.FileExists(videoPathMP4) || shared.FileExists(videoPathMKV) {
-					return 2
-				}
-			}
+// EpisodeVideoPath returns the path of the video for an indexed episode, or "" if there is none
+func EpisodeVideoPath(baseDir string, ep shared.ChapterInterval) string {
+	seasonDir := filepath.Join(baseDir, ep.SeasonKey)
 
+	for _, ext := range []string{".mp4", ".mkv"} {
+		videoPath := filepath.Join(seasonDir, ep.Title+ext)
+		if shared.FileExists(videoPath) {
+			return videoPath
 		}
 	}
 
-	return 0
+	return ""
 }
 
 // HaveMetadata checks if metadata exists for given chapterRange.
@@ -57,24 +67,25 @@ func HaveMetadata(chapterRange string) bool {
 		return false
 	}
 
-	LoadMetadataCache()
-	norm := shared.NormalizeDash(chapterRange)
-
-	for _, season := range metadataCache.Seasons {
-		// season range match instantly
-		if shared.NormalizeDash(season.Range) == norm {
-			return true
-		}
+	index := LoadMetadataCache()
 
-		// match individual episodes
-		for epRange := range season.EpisodeRange {
-			if shared.NormalizeDash(epRange) == norm {
-				return true
-			}
-		}
+	// season range match instantly
+	if _, ok := index.Chapters.SeasonExact(chapterRange); ok {
+		return true
 	}
 
-	return false
+	// match individual episodes
+	_, ok := index.Chapters.EpisodeExact(chapterRange, "")
+	return ok
+}
+
+// IsArcRelease tells if a release with this chapter range is a whole arc, not a single episode
+func IsArcRelease(chapterRange string) bool {
+	if chapterRange == "" {
+		return false
+	}
+	_, ok := LoadMetadataCache().Chapters.SeasonExact(chapterRange)
+	return ok
 }
 
 // video and .nfo file counter. Returns: number of videos matched with episode .nfo file, number of episode .nfo files
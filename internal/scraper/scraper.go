@@ -78,6 +78,19 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 	torrentLink, _ := s.Find(config.Fields.TorrentLink).Attr("href")
 	date := s.Find(config.Fields.UploadDate).Text()
 
+	var size int64
+	if config.Fields.Size != "" {
+		size = shared.ParseSize(s.Find(config.Fields.Size).Text())
+	}
+
+	magnet := ""
+	if config.Fields.Magnet != "" {
+		magnet, _ = s.Find(config.Fields.Magnet).Attr("href")
+	}
+	if shared.IsMagnet(torrentLink) {
+		magnet, torrentLink = torrentLink, ""
+	}
+
 	// Validate based on config
 	if config.Validation.RequiredInTitle != "" {
 		if !strings.Contains(strings.ToLower(title), strings.ToLower(config.Validation.RequiredInTitle)) {
@@ -85,13 +98,18 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 		}
 	}
 
-	if torrentLink == "" {
+	infoHash := shared.InfoHashFromMagnet(magnet)
+	if infoHash == "" && config.Fields.InfoHash != "" {
+		infoHash = shared.NormalizeInfoHash(s.Find(config.Fields.InfoHash).Text())
+	}
+
+	if torrentLink == "" && infoHash == "" {
 		return shared.TorrentEntry{}, false
 	}
 
 	// Extract torrent ID using regex from config
 	torrentID := 0
-	if config.Fields.TorrentID != "" {
+	if config.Fields.TorrentID != "" && torrentLink != "" {
 		re := regexp.MustCompile(config.Fields.TorrentID)
 		matches := re.FindStringSubmatch(torrentLink)
 		if len(matches) >= 2 {
@@ -99,15 +117,26 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 		}
 	}
 
+	// no usable indexer id, the infohash identifies the release
+	if torrentID <= 0 {
+		if infoHash == "" {
+			return shared.TorrentEntry{}, false
+		}
+		torrentID = shared.IDFromInfoHash(infoHash)
+		if magnet == "" {
+			magnet = shared.MagnetFromInfoHash(infoHash)
+		}
+	}
+
 	// Parse the rest of the data
 	chapterRange := shared.ExtractChapterRangeFromTitle(title)
 	rawIndex := extractRawIndex(chapterRange)
 	seeders, _ := strconv.Atoi(strings.TrimSpace(seedersStr))
-	quality := parseQuality(title)
-	torrentName := extractTorrentName(title)
+	quality := ParseQuality(title)
+	torrentName := ExtractTorrentName(title)
 
 	// Make torrent link absolute if needed
-	if !strings.HasPrefix(torrentLink, "http") {
+	if torrentLink != "" && !strings.HasPrefix(torrentLink, "http") {
 		torrentLink = baseURL + torrentLink
 	}
 
@@ -117,6 +146,8 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 
 	isExtended := isExtended(title)
 
+	isBundle := metadata.IsArcRelease(chapterRange)
+
 	return shared.TorrentEntry{
 		Title:         title,
 		Quality:       quality,
@@ -125,12 +156,16 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 		RawIndex:      rawIndex,
 		TorrentLink:   torrentLink,
 		TorrentID:     torrentID,
+		Magnet:        magnet,
+		InfoHash:      infoHash,
 		ChapterRange:  chapterRange,
 		IsSpecial:     chapterRange == "",
 		MetaDataAvail: metaDataAvail,
 		HaveIt:        videoStatus,
 		Date:          date,
 		IsExtended:    isExtended,
+		Size:          size,
+		IsBundle:      isBundle,
 	}, true
 }
 
@@ -138,7 +173,7 @@ func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string
 func processEntries(rawEntries []shared.TorrentEntry) []shared.TorrentEntry {
 	// filter out torrents with 0 seeders
 	filtered := make([]shared.TorrentEntry, 0, len(rawEntries))
-	for _, entry := range rawEntries {
+	for _, entry := range dedupeByInfoHash(rawEntries) {
 		if entry.Seeders > 0 {
 			filtered = append(filtered, entry)
 		}
@@ -169,14 +204,49 @@ func processEntries(rawEntries []shared.TorrentEntry) []shared.TorrentEntry {
 	return filtered
 }
 
+// the same release listed twice, e.g on two pages or indexers, is kept once with the most seeders.
+// entries without a known infohash are kept as they are.
+func dedupeByInfoHash(entries []shared.TorrentEntry) []shared.TorrentEntry {
+	seen := make(map[string]int)
+	deduped := make([]shared.TorrentEntry, 0, len(entries))
+
+	for _, e := range entries {
+		if e.InfoHash == "" {
+			deduped = append(deduped, e)
+			continue
+		}
+
+		i, ok := seen[e.InfoHash]
+		if !ok {
+			seen[e.InfoHash] = len(deduped)
+			deduped = append(deduped, e)
+			continue
+		}
+
+		// prefer the entry with an indexer id, it has a .torrent to fetch
+		kept := &deduped[i]
+		if kept.TorrentID <= 0 && e.TorrentID > 0 {
+			e.Seeders = max(e.Seeders, kept.Seeders)
+			*kept = e
+		} else {
+			kept.Seeders = max(e.Seeders, kept.Seeders)
+		}
+		if kept.Magnet == "" {
+			kept.Magnet = e.Magnet
+		}
+	}
+
+	return deduped
+}
+
 func isExtended(title string) bool {
 	title = strings.ToLower(title)
 
 	return strings.Contains(title, "extended")
 }
 
-// parseQuality returns video quality based on title string
-func parseQuality(title string) string {
+// ParseQuality returns video quality based on title string
+func ParseQuality(title string) string {
 	title = strings.ToLower(title)
 
 	switch {
@@ -205,8 +275,8 @@ func extractRawIndex(rangeStr string) int {
 	return 9999
 }
 
-// extracts torrent name for display
-func extractTorrentName(title string) string {
+// ExtractTorrentName returns the name of a release for display
+func ExtractTorrentName(title string) string {
 	parts := regexp.MustCompile(`\[[^\]]+\]`).Split(title, -1)
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
@@ -216,3 +286,54 @@ func extractTorrentName(title string) string {
 	}
 	return "Unknown"
 }
+
+// FindAlternative returns the best other release for the same chapter range, preferring the same quality and more seeders.
+// Blocked releases and excluded ids are skipped. nil if there is none.
+func FindAlternative(entries []shared.TorrentEntry, chapterRange, quality string, exclude map[int]bool) *shared.TorrentEntry {
+	if chapterRange == "" {
+		return nil
+	}
+
+	blocked := make(map[int]bool)
+	for _, b := range shared.LoadBlocklist() {
+		blocked[b.TorrentID] = true
+	}
+
+	var best *shared.TorrentEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.ChapterRange != chapterRange || exclude[e.TorrentID] || blocked[e.TorrentID] {
+			continue
+		}
+
+		if best == nil {
+			best = e
+			continue
+		}
+
+		sameQuality, bestSameQuality := e.Quality == quality, best.Quality == quality
+		if sameQuality != bestSameQuality {
+			if sameQuality {
+				best = e
+			}
+			continue
+		}
+
+		if e.Seeders > best.Seeders {
+			best = e
+		}
+	}
+
+	return best
+}
+
+// FindByDownloadKey returns the entry with the most seeders for a download key, nil if there is none
+func FindByDownloadKey(entries []shared.TorrentEntry, key int) *shared.TorrentEntry {
+	var match *shared.TorrentEntry
+	for i := range entries {
+		if entries[i].DownloadKey == key && (match == nil || entries[i].Seeders > match.Seeders) {
+			match = &entries[i]
+		}
+	}
+	return match
+}
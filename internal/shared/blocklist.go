@@ -0,0 +1,84 @@
+// shared/blocklist.go
+package shared
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// a release that failed, skipped when looking for alternatives
+type BlockedRelease struct {
+	TorrentID int       `json:"torrent_id"`
+	Title     string    `json:"title"`
+	Reason    string    `json:"reason"`
+	Added     time.Time `json:"added"`
+}
+
+var blocklistMu sync.Mutex
+
+func blocklistPath() string {
+	return filepath.Join(ConfigDir(), "blocklist.json")
+}
+
+// LoadBlocklist returns every blocked release. A missing file means an empty list.
+func LoadBlocklist() []BlockedRelease {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	return loadBlocklist()
+}
+
+// BlockRelease adds a release to the blocklist
+func BlockRelease(torrentID int, title, reason string) error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+
+	list := loadBlocklist()
+	for _, b := range list {
+		if b.TorrentID == torrentID {
+			return nil
+		}
+	}
+
+	list = append(list, BlockedRelease{
+		TorrentID: torrentID,
+		Title:     title,
+		Reason:    reason,
+		Added:     time.Now(),
+	})
+	return saveBlocklist(list)
+}
+
+// ClearBlocklist removes all blocked releases
+func ClearBlocklist() error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+
+	if err := os.Remove(blocklistPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func loadBlocklist() []BlockedRelease {
+	data, err := os.ReadFile(blocklistPath())
+	if err != nil {
+		return nil
+	}
+
+	var list []BlockedRelease
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+	return list
+}
+
+func saveBlocklist(list []BlockedRelease) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(blocklistPath(), data, 0644)
+}
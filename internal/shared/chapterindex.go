@@ -0,0 +1,218 @@
+// shared/chapterindex.go
+package shared
+
+import (
+	"sort"
+)
+
+// ChapterInterval is a single season or episode entry in the ChapterIndex
+type ChapterInterval struct {
+	Start        int    // first chapter
+	End          int    // last chapter
+	SeasonKey    string // e.g "Season 5" or "Specials"
+	SeasonNumber int    // season number from the index
+	ChapterRange string // normalized range key, as stored in the MetadataIndex
+	Title        string // episode title, empty for season entries
+}
+
+// ChapterIndex answers "which seasons/episodes cover chapters x-y" without walking the MetadataIndex maps.
+// Results are always returned in the same order: by start, end, season number and title.
+type ChapterIndex struct {
+	seasons  intervalTree
+	episodes intervalTree
+}
+
+// BuildChapterIndex creates the interval index for a MetadataIndex
+func BuildChapterIndex(index *MetadataIndex) *ChapterIndex {
+	var seasons, episodes []ChapterInterval
+
+	if index != nil {
+		for seasonKey, season := range index.Seasons {
+			if start, end := ParseRange(NormalizeDash(season.Range)); start >= 0 && end >= start {
+				seasons = append(seasons, ChapterInterval{
+					Start:        start,
+					End:          end,
+					SeasonKey:    seasonKey,
+					SeasonNumber: season.SeasonNumber,
+					ChapterRange: NormalizeDash(season.Range),
+				})
+			}
+
+			for epRange, ep := range season.EpisodeRange {
+				start, end := ParseRange(NormalizeDash(epRange))
+				if start < 0 || end < start {
+					continue
+				}
+				episodes = append(episodes, ChapterInterval{
+					Start:        start,
+					End:          end,
+					SeasonKey:    seasonKey,
+					SeasonNumber: season.SeasonNumber,
+					ChapterRange: NormalizeDash(epRange),
+					Title:        ep.Title,
+				})
+			}
+		}
+	}
+
+	return &ChapterIndex{
+		seasons:  newIntervalTree(seasons),
+		episodes: newIntervalTree(episodes),
+	}
+}
+
+// Seasons returns all seasons overlapping chapters start-end
+func (ci *ChapterIndex) Seasons(start, end int) []ChapterInterval {
+	if ci == nil {
+		return nil
+	}
+	return ci.seasons.overlapping(start, end)
+}
+
+// Episodes returns all episodes overlapping chapters start-end
+func (ci *ChapterIndex) Episodes(start, end int) []ChapterInterval {
+	if ci == nil {
+		return nil
+	}
+	return ci.episodes.overlapping(start, end)
+}
+
+// SeasonFor returns the season fully containing chapters start-end. The narrowest season wins if several do.
+func (ci *ChapterIndex) SeasonFor(start, end int) (ChapterInterval, bool) {
+	var best ChapterInterval
+	found := false
+
+	for _, s := range ci.Seasons(start, end) {
+		if s.Start > start || s.End < end {
+			continue
+		}
+		if !found || s.End-s.Start < best.End-best.Start {
+			best = s
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// SeasonExact returns the season whose range is exactly chapterRange
+func (ci *ChapterIndex) SeasonExact(chapterRange string) (ChapterInterval, bool) {
+	return exactMatch(ci.Seasons, chapterRange, "")
+}
+
+// EpisodeExact returns the episode whose range is exactly chapterRange. seasonKey limits the search to one season, leave empty for all.
+func (ci *ChapterIndex) EpisodeExact(chapterRange, seasonKey string) (ChapterInterval, bool) {
+	return exactMatch(ci.Episodes, chapterRange, seasonKey)
+}
+
+// EpisodesIn returns all episodes overlapping chapters start-end in a single season
+func (ci *ChapterIndex) EpisodesIn(seasonKey string, start, end int) []ChapterInterval {
+	var out []ChapterInterval
+	for _, ep := range ci.Episodes(start, end) {
+		if ep.SeasonKey == seasonKey {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+// helper for the exact lookups
+func exactMatch(query func(int, int) []ChapterInterval, chapterRange, seasonKey string) (ChapterInterval, bool) {
+	norm := NormalizeDash(chapterRange)
+	start, end := ParseRange(norm)
+	if start < 0 || end < start {
+		return ChapterInterval{}, false
+	}
+
+	for _, iv := range query(start, end) {
+		if iv.Start == start && iv.End == end && (seasonKey == "" || iv.SeasonKey == seasonKey) {
+			return iv, true
+		}
+	}
+
+	return ChapterInterval{}, false
+}
+
+// static interval tree. items are sorted by start, the tree is implicit in the slice (root is the middle element),
+// maxEnd holds the highest end in the subtree rooted at each element.
+type intervalTree struct {
+	items  []ChapterInterval
+	maxEnd []int
+}
+
+func newIntervalTree(items []ChapterInterval) intervalTree {
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.End != b.End {
+			return a.End < b.End
+		}
+		if a.SeasonNumber != b.SeasonNumber {
+			return a.SeasonNumber < b.SeasonNumber
+		}
+		if a.SeasonKey != b.SeasonKey {
+			return a.SeasonKey < b.SeasonKey
+		}
+		return a.Title < b.Title
+	})
+
+	t := intervalTree{
+		items:  items,
+		maxEnd: make([]int, len(items)),
+	}
+	t.build(0, len(items))
+
+	return t
+}
+
+func (t intervalTree) build(lo, hi int) int {
+	if lo >= hi {
+		return -1
+	}
+	mid := (lo + hi) / 2
+
+	max := t.items[mid].End
+	if left := t.build(lo, mid); left > max {
+		max = left
+	}
+	if right := t.build(mid+1, hi); right > max {
+		max = right
+	}
+	t.maxEnd[mid] = max
+
+	return max
+}
+
+// returns all items overlapping start-end, in sorted order
+func (t intervalTree) overlapping(start, end int) []ChapterInterval {
+	var out []ChapterInterval
+	t.query(0, len(t.items), start, end, &out)
+	return out
+}
+
+func (t intervalTree) query(lo, hi, start, end int, out *[]ChapterInterval) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+
+	// nothing in this subtree reaches start
+	if t.maxEnd[mid] < start {
+		return
+	}
+
+	t.query(lo, mid, start, end, out)
+
+	// everything right of mid starts after the query
+	if t.items[mid].Start > end {
+		return
+	}
+
+	if RangesOverlap(t.items[mid].Start, t.items[mid].End, start, end) {
+		*out = append(*out, t.items[mid])
+	}
+
+	t.query(mid+1, hi, start, end, out)
+}
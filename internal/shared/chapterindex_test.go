@@ -0,0 +1,81 @@
+package shared
+
+import "testing"
+
+func testIndex() *MetadataIndex {
+	return &MetadataIndex{
+		Seasons: map[string]SeasonIndex{
+			"Season 1": {
+				Range:        "1-7",
+				SeasonNumber: 1,
+				EpisodeRange: map[string]EpisodeData{
+					"1-3": {Title: "S01E01"},
+					"4-7": {Title: "S01E02"},
+				},
+			},
+			"Season 2": {
+				Range:        "8-21",
+				SeasonNumber: 2,
+				EpisodeRange: map[string]EpisodeData{
+					"8-11":  {Title: "S02E01"},
+					"12-21": {Title: "S02E02"},
+				},
+			},
+			"Season 3": {
+				Range:        "8-11",
+				SeasonNumber: 3,
+				EpisodeRange: map[string]EpisodeData{
+					"8-11": {Title: "S03E01"},
+				},
+			},
+		},
+	}
+}
+
+func TestChapterIndexEpisodes(t *testing.T) {
+	ci := BuildChapterIndex(testIndex())
+
+	tests := []struct {
+		start, end int
+		expected   []string
+	}{
+		{1, 1, []string{"S01E01"}},
+		{5, 9, []string{"S01E02", "S02E01", "S03E01"}},
+		{12, 30, []string{"S02E02"}},
+		{22, 30, nil},
+	}
+
+	for _, tc := range tests {
+		got := ci.Episodes(tc.start, tc.end)
+		if len(got) != len(tc.expected) {
+			t.Fatalf("range %d-%d: got %d episodes, want %d", tc.start, tc.end, len(got), len(tc.expected))
+		}
+		for i, ep := range got {
+			if ep.Title != tc.expected[i] {
+				t.Errorf("range %d-%d: got %q at %d, want %q", tc.start, tc.end, ep.Title, i, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestChapterIndexSeasons(t *testing.T) {
+	ci := BuildChapterIndex(testIndex())
+
+	// narrowest containing season wins
+	if s, ok := ci.SeasonFor(8, 11); !ok || s.SeasonKey != "Season 3" {
+		t.Errorf("SeasonFor(8, 11): got %q, want %q", s.SeasonKey, "Season 3")
+	}
+	if s, ok := ci.SeasonFor(12, 13); !ok || s.SeasonKey != "Season 2" {
+		t.Errorf("SeasonFor(12, 13): got %q, want %q", s.SeasonKey, "Season 2")
+	}
+	if _, ok := ci.SeasonFor(6, 9); ok {
+		t.Errorf("SeasonFor(6, 9): expected no season")
+	}
+
+	if ep, ok := ci.EpisodeExact("8–11", "Season 2"); !ok || ep.Title != "S02E01" {
+		t.Errorf("EpisodeExact: got %q, want %q", ep.Title, "S02E01")
+	}
+	if _, ok := ci.SeasonExact("1-6"); ok {
+		t.Errorf("SeasonExact(1-6): expected no season")
+	}
+}
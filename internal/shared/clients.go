@@ -0,0 +1,206 @@
+// shared/clients.go
+package shared
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bundle rules
+const (
+	BundlesOnly  = "only"
+	BundlesNever = "never"
+)
+
+// what client rules look at
+type Release struct {
+	Quality string
+	Size    int64 // bytes, 0 if unknown
+	Bundle  bool
+}
+
+func (e TorrentEntry) Release() Release {
+	return Release{Quality: e.Quality, Size: e.Size, Bundle: e.IsBundle}
+}
+
+func (td *TorrentDownload) Release() Release {
+	return Release{Quality: td.Quality, Size: td.TotalSize, Bundle: td.Bundle}
+}
+
+// ClientName is how downloads refer to the client, its type if it has no name
+func (c TorrentClientConfig) ClientName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// true for clients opforjellyfin talks to, not the built-in one
+func (c TorrentClientConfig) External() bool {
+	return c.Type != "" && c.Type != "internal"
+}
+
+// returns the external clients, preferred first. TorrentClient goes first among equal priorities
+func (c Config) ExternalClients() []TorrentClientConfig {
+	var clients []TorrentClientConfig
+	for _, tc := range append([]TorrentClientConfig{c.TorrentClient}, c.Clients...) {
+		if tc.External() {
+			clients = append(clients, tc)
+		}
+	}
+
+	sort.SliceStable(clients, func(i, j int) bool {
+		return clients[i].Priority < clients[j].Priority
+	})
+	return clients
+}
+
+// returns the clients whose rules accept the release, preferred first. when none does, a primary external
+// client takes it anyway. nil means the internal client downloads it
+func (c Config) ClientsFor(r Release) []TorrentClientConfig {
+	var clients []TorrentClientConfig
+	for _, tc := range c.ExternalClients() {
+		if tc.Rules.Accepts(r) {
+			clients = append(clients, tc)
+		}
+	}
+
+	if len(clients) == 0 && c.TorrentClient.External() {
+		return []TorrentClientConfig{c.TorrentClient}
+	}
+	return clients
+}
+
+// returns the client that has the download. downloads from before named clients,
+// or of a client that was removed since, belong to the primary one
+func (c Config) ClientFor(td *TorrentDownload) TorrentClientConfig {
+	return c.ClientByName(td.Client)
+}
+
+// ClientByName returns the named external client, or the primary one if there is none by that name
+func (c Config) ClientByName(name string) TorrentClientConfig {
+	if tc, ok := c.ClientNamed(name); ok {
+		return tc
+	}
+	return c.TorrentClient
+}
+
+// ClientNamed finds an external client by its name
+func (c Config) ClientNamed(name string) (TorrentClientConfig, bool) {
+	if name == "" {
+		return TorrentClientConfig{}, false
+	}
+	for _, tc := range c.ExternalClients() {
+		if tc.ClientName() == name {
+			return tc, true
+		}
+	}
+	return TorrentClientConfig{}, false
+}
+
+// adds a client to Clients, replacing the one with the same name
+func (c *Config) SetClient(tc TorrentClientConfig) {
+	for i, existing := range c.Clients {
+		if existing.ClientName() == tc.ClientName() {
+			c.Clients[i] = tc
+			return
+		}
+	}
+	c.Clients = append(c.Clients, tc)
+}
+
+// removes a client from Clients, false if there is none by that name. the primary client stays
+func (c *Config) RemoveClient(name string) bool {
+	for i, existing := range c.Clients {
+		if existing.ClientName() == name {
+			c.Clients = append(c.Clients[:i], c.Clients[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateClients checks client names are unique and rules make sense
+func (c Config) ValidateClients() error {
+	seen := map[string]bool{}
+	for _, tc := range c.ExternalClients() {
+		name := tc.ClientName()
+		if seen[name] {
+			return fmt.Errorf("more than one client named %q, give each a name", name)
+		}
+		seen[name] = true
+
+		if err := tc.Rules.Validate(); err != nil {
+			return fmt.Errorf("client %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r ClientRules) Validate() error {
+	if r.Bundles != "" && r.Bundles != BundlesOnly && r.Bundles != BundlesNever {
+		return fmt.Errorf("bundles must be %q, %q or empty, got %q", BundlesOnly, BundlesNever, r.Bundles)
+	}
+	if r.MinSizeMB < 0 || r.MaxSizeMB < 0 {
+		return fmt.Errorf("sizes can't be negative")
+	}
+	if r.MaxSizeMB > 0 && r.MinSizeMB > r.MaxSizeMB {
+		return fmt.Errorf("min size %d MB is above max size %d MB", r.MinSizeMB, r.MaxSizeMB)
+	}
+	return nil
+}
+
+// true if the rules allow the release. like libraries, quality "n/a" or "" passes resolution rules
+func (r ClientRules) Accepts(rel Release) bool {
+	if len(r.Resolutions) > 0 && rel.Quality != "" && rel.Quality != "n/a" && !slices.Contains(r.Resolutions, rel.Quality) {
+		return false
+	}
+
+	const mb = 1 << 20
+	if rel.Size > 0 {
+		if r.MinSizeMB > 0 && rel.Size < r.MinSizeMB*mb {
+			return false
+		}
+		if r.MaxSizeMB > 0 && rel.Size > r.MaxSizeMB*mb {
+			return false
+		}
+	}
+
+	switch r.Bundles {
+	case BundlesOnly:
+		return rel.Bundle
+	case BundlesNever:
+		return !rel.Bundle
+	}
+	return true
+}
+
+var sizeRegex = regexp.MustCompile(`(?i)^([\d.,]+)\s*([KMGT]?)(i?)B$`)
+
+// ParseSize reads an indexer size like "1.2 GiB" or "700 MB" as bytes, 0 if it can't
+func ParseSize(s string) int64 {
+	m := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+
+	n, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+
+	base := 1000.0
+	if m[3] != "" {
+		base = 1024
+	}
+	exp := 0
+	if m[2] != "" {
+		exp = strings.Index("KMGT", strings.ToUpper(m[2])) + 1
+	}
+	return int64(n * math.Pow(base, float64(exp)))
+}
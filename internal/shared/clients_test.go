@@ -0,0 +1,63 @@
+package shared
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClientsFor(t *testing.T) {
+	cfg := Config{
+		TorrentClient: TorrentClientConfig{Type: "qbittorrent"},
+		Clients: []TorrentClientConfig{
+			{Name: "nas", Type: "transmission", Priority: 1, Rules: ClientRules{MinSizeMB: 5000, Bundles: BundlesOnly}},
+			{Name: "box", Type: "deluge", Priority: -1, Rules: ClientRules{Resolutions: []string{"720p"}}},
+		},
+	}
+	names := func(clients []TorrentClientConfig) (out []string) {
+		for _, tc := range clients {
+			out = append(out, tc.ClientName())
+		}
+		return out
+	}
+
+	cases := []struct {
+		rel  Release
+		want []string
+	}{
+		{Release{Quality: "720p", Size: 8 << 30, Bundle: true}, []string{"box", "qbittorrent", "nas"}},
+		{Release{Quality: "1080p", Size: 8 << 30, Bundle: true}, []string{"qbittorrent", "nas"}},
+		{Release{Quality: "1080p", Size: 2 << 30, Bundle: true}, []string{"qbittorrent"}},
+		{Release{Quality: "1080p", Bundle: true}, []string{"qbittorrent", "nas"}}, // unknown size passes
+	}
+	for _, c := range cases {
+		if got := names(cfg.ClientsFor(c.rel)); !slices.Equal(got, c.want) {
+			t.Errorf("ClientsFor(%+v) = %v, want %v", c.rel, got, c.want)
+		}
+	}
+
+	// nothing matches: the primary takes it, or the internal client if the primary is internal
+	cfg.TorrentClient.Rules = ClientRules{Bundles: BundlesNever}
+	if got := names(cfg.ClientsFor(Release{Quality: "1080p", Size: 1 << 20, Bundle: true})); !slices.Equal(got, []string{"qbittorrent"}) {
+		t.Errorf("expected the primary as fallback, got %v", got)
+	}
+	cfg.TorrentClient = TorrentClientConfig{Type: "internal"}
+	if got := cfg.ClientsFor(Release{Quality: "1080p", Size: 1 << 20}); got != nil {
+		t.Errorf("expected the internal client, got %v", names(got))
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1.2 GiB":  1288490188,
+		"700 MB":   700000000,
+		"700MiB":   700 << 20,
+		"1,024 KB": 1024000,
+		"512 B":    512,
+		"huge":     0,
+	}
+	for in, want := range cases {
+		if got := ParseSize(in); got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
@@ -58,6 +58,11 @@ func EnsureConfigExists() string {
 
 // returns the config filepath from the OS's default config directory
 func getConfigPath() string {
+	return filepath.Join(ConfigDir(), "config.json")
+}
+
+// ConfigDir returns the opforjellyfin directory in the OS's default config directory, creating it if needed
+func ConfigDir() string {
 	dirname, err := os.UserConfigDir()
 	if err != nil {
 		log.Fatalf("could not determine config directory: %v", err)
@@ -67,5 +72,5 @@ func getConfigPath() string {
 	if err != nil {
 		log.Fatalf("could not create config dir: %v", err)
 	}
-	return filepath.Join(path, "config.json")
+	return path
 }
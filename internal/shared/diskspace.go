@@ -0,0 +1,54 @@
+// shared/diskspace.go
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultReserveMB = 1024
+	defaultWarnMB    = 10240
+)
+
+// Reserve is the free space in bytes downloads leave on every disk they write to
+func (c DiskConfig) Reserve() int64 {
+	if c.ReserveMB <= 0 {
+		return defaultReserveMB << 20
+	}
+	return c.ReserveMB << 20
+}
+
+// Warn is the free space in bytes below which a disk counts as low
+func (c DiskConfig) Warn() int64 {
+	if c.WarnMB <= 0 {
+		return defaultWarnMB << 20
+	}
+	return c.WarnMB << 20
+}
+
+// DiskUsage returns the free and total bytes of the filesystem path is on.
+// a path that doesn't exist yet, e.g a new library, is looked up through its closest parent
+func DiskUsage(path string) (free, total int64, err error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return diskUsage(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, 0, fmt.Errorf("no existing directory above %s", path)
+		}
+		dir = parent
+	}
+}
+
+// e.g "12.3 GB"
+func FormatGB(bytes int64) string {
+	return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+}
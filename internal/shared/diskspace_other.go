@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+// shared/diskspace_other.go
+package shared
+
+import "errors"
+
+// free space isn't checked here, downloads are never held back for it
+func diskUsage(dir string) (free, total int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
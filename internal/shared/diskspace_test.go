@@ -0,0 +1,32 @@
+package shared
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+
+	free, total, err := DiskUsage(dir)
+	if err != nil {
+		t.Skipf("free space not supported here: %v", err)
+	}
+	if free <= 0 || total < free {
+		t.Errorf("DiskUsage(%q) = %d free of %d", dir, free, total)
+	}
+
+	// a library that doesn't exist yet is on its parent's disk
+	if _, missingTotal, err := DiskUsage(filepath.Join(dir, "new", "library")); err != nil || missingTotal != total {
+		t.Errorf("expected the parent's disk for a missing dir, got %d, %v", missingTotal, err)
+	}
+}
+
+func TestDiskConfigDefaults(t *testing.T) {
+	if got := (DiskConfig{}).Reserve(); got != 1<<30 {
+		t.Errorf("default reserve = %d", got)
+	}
+	if got := (DiskConfig{ReserveMB: 500, WarnMB: 2048}); got.Reserve() != 500<<20 || got.Warn() != 2<<30 {
+		t.Errorf("configured = %d, %d", got.Reserve(), got.Warn())
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+// shared/diskspace_unix.go
+package shared
+
+import "syscall"
+
+func diskUsage(dir string) (free, total int64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	// available to unprivileged users, not the blocks reserved for root
+	return int64(st.Bavail) * int64(st.Bsize), int64(st.Blocks) * int64(st.Bsize), nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+// shared/diskspace_windows.go
+package shared
+
+import "golang.org/x/sys/windows"
+
+func diskUsage(dir string) (free, total int64, err error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var available, size, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &size, &totalFree); err != nil {
+		return 0, 0, err
+	}
+	return int64(available), int64(size), nil
+}
@@ -9,8 +9,11 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
+const staleTempDirAge = 24 * time.Hour
+
 var (
 	activeDownloads = make(map[int]*TorrentDownload)
 	mu              sync.RWMutex
@@ -44,7 +47,26 @@ func GetActiveDownloads() []*TorrentDownload {
 	return list
 }
 
-// clear cache and remove temp download directories
+// FindDownload returns the active download for a release, matched by id or infohash. nil if there is none
+func FindDownload(torrentID int, infoHash string) *TorrentDownload {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if td, ok := activeDownloads[torrentID]; ok {
+		return td
+	}
+	if infoHash == "" {
+		return nil
+	}
+	for _, td := range activeDownloads {
+		if td.InfoHash == infoHash {
+			return td
+		}
+	}
+	return nil
+}
+
+// clear cache and remove leftover temp dirs. partial downloads are kept in the incomplete dir for resuming.
 func ClearActiveDownloads() {
 	mu.Lock()
 	defer mu.Unlock()
@@ -52,17 +74,25 @@ func ClearActiveDownloads() {
 	CleanupTempDirs()
 }
 
+// older versions downloaded into opfor-tmp-* dirs. only stale ones are removed, a running old version may still use the rest
 func CleanupTempDirs() error {
 	files, err := os.ReadDir(os.TempDir())
 	if err != nil {
 		return err
 	}
 	for _, f := range files {
-		if strings.HasPrefix(f.Name(), "opfor-tmp-") {
-			path := filepath.Join(os.TempDir(), f.Name())
-			if err := os.RemoveAll(path); err != nil {
-				fmt.Printf("⚠️  Failed to remove %s: %v\n", path, err)
-			}
+		if !strings.HasPrefix(f.Name(), "opfor-tmp-") {
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempDirAge {
+			continue
+		}
+
+		path := filepath.Join(os.TempDir(), f.Name())
+		if err := os.RemoveAll(path); err != nil {
+			fmt.Printf("⚠️  Failed to remove %s: %v\n", path, err)
 		}
 	}
 	return nil
@@ -75,6 +105,28 @@ func (td *TorrentDownload) MarkPlaced(msg string) {
 	SaveTorrentDownload(td)
 }
 
+// Failed reports whether a download ended with an error, those are shown with ❌
+func (td *TorrentDownload) Failed() bool {
+	return strings.HasPrefix(td.PlacementProgress, "❌")
+}
+
+// MarkPending shows why a queued download has not started yet
+func (td *TorrentDownload) MarkPending(reason string) {
+	td.Pending = true
+	td.PlacementProgress = "⏳ " + reason
+	SaveTorrentDownload(td)
+}
+
+// ClearPending marks a pending download as started
+func (td *TorrentDownload) ClearPending() {
+	if !td.Pending {
+		return
+	}
+	td.Pending = false
+	td.PlacementProgress = ""
+	SaveTorrentDownload(td)
+}
+
 // RemoveDownload removes a specific download from the active list
 func RemoveDownload(torrentID int) {
 	mu.Lock()
@@ -96,3 +148,26 @@ func CleanupCompletedDownloads() int {
 	}
 	return removed
 }
+
+// SwitchRelease points a download at another release of the same chapters, e.g after a stall
+func (td *TorrentDownload) SwitchRelease(entry TorrentEntry, title string) {
+	RemoveDownload(td.TorrentID)
+
+	td.Title = title
+	td.TorrentID = entry.TorrentID
+	td.FullTitle = entry.Title
+	td.Quality = entry.Quality
+	td.Magnet = entry.Magnet
+	td.InfoHash = entry.InfoHash
+	td.Started = time.Now()
+	td.Progress = 0
+	td.TotalSize = 0
+	td.SavePath = ""
+	td.ExternalHash = ""
+	td.PlacedFiles = nil
+	td.SelectedFiles = nil
+	td.FilesSelected = false
+	td.Attempt++
+
+	SaveTorrentDownload(td)
+}
@@ -1,7 +1,6 @@
 package shared
 
 import (
-	"fmt"
 	"io"
 	"opforjellyfin/internal/logger"
 	"os"
@@ -14,36 +13,20 @@ var (
 	dirMutex sync.Mutex
 )
 
-// CreateTempTorrentDir safely creates a temporary directory for torrent downloads
-func CreateTempTorrentDir(torrentID int) (string, error) {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
-
-	tmpDir := filepath.Join(os.TempDir(), fmt.Sprintf("opfor-tmp-%d", torrentID))
-
-	// Check if it already exists
-	if info, err := os.Stat(tmpDir); err == nil && info.IsDir() {
-		logger.Log(false, "Temp dir already exists: %s", tmpDir)
-		return tmpDir, nil
-	}
-
-	// Create with MkdirAll (safe for concurrent calls)
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
-	}
-
-	logger.Log(false, "Created temp dir: %s", tmpDir)
-	return tmpDir, nil
-}
-
 // SafeMoveFile moves or hardlinks a file depending on context
 // This function is thread-safe and handles concurrent file operations
 // Always tries hardlink first to preserve files for seeding, falls back to copy if needed
 func SafeMoveFile(src, dst string) error {
+	return PlaceFile(src, dst, PlacementHardlink)
+}
+
+// PlaceFile puts src at dst using a placement strategy (hardlink, copy or symlink). Empty means hardlink.
+// Hardlinks fall back to copy, e.g across filesystems. Thread-safe like SafeMoveFile.
+func PlaceFile(src, dst, placement string) error {
 	dirMutex.Lock()
 	defer dirMutex.Unlock()
 
-	logger.Log(false, "sfm: starting operation from %s to %s", src, dst)
+	logger.Log(false, "sfm: starting %s from %s to %s", placement, src, dst)
 
 	dstDir := filepath.Dir(dst)
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
@@ -56,6 +39,28 @@ func SafeMoveFile(src, dst string) error {
 		return nil
 	}
 
+	switch placement {
+	case PlacementCopy:
+		if err := copyFileInternal(src, dst, 0644); err != nil {
+			logger.Log(true, "sfm: copyFile failed: %v", err)
+			return err
+		}
+		logger.Log(false, "sfm: copyFile succeeded")
+		return nil
+
+	case PlacementSymlink:
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(absSrc, dst); err != nil {
+			logger.Log(true, "sfm: symlink failed: %v", err)
+			return err
+		}
+		logger.Log(false, "sfm: symlink succeeded")
+		return nil
+	}
+
 	logger.Log(false, "sfm: attempting hardlink from %s to %s", src, dst)
 	if err := os.Link(src, dst); err != nil {
 		logger.Log(false, "sfm: hardlink failed (%v), trying copy", err)
@@ -0,0 +1,83 @@
+// shared/libraries.go
+package shared
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// placement strategies
+const (
+	PlacementHardlink = "hardlink"
+	PlacementCopy     = "copy"
+	PlacementSymlink  = "symlink"
+)
+
+var PlacementStrategies = []string{PlacementHardlink, PlacementCopy, PlacementSymlink}
+
+// returns all configured libraries. without a library list, TargetDir is the only library
+func (c Config) AllLibraries() []LibraryConfig {
+	if len(c.Libraries) > 0 {
+		return c.Libraries
+	}
+	if c.TargetDir == "" {
+		return nil
+	}
+	return []LibraryConfig{{Name: "default", TargetDir: c.TargetDir}}
+}
+
+// changes the primary target directory, the library pointing at the old one follows
+func (c *Config) SetTargetDir(dir string) {
+	for i, lib := range c.Libraries {
+		if c.TargetDir != "" && filepath.Clean(lib.TargetDir) == filepath.Clean(c.TargetDir) {
+			c.Libraries[i].TargetDir = dir
+		}
+	}
+	c.TargetDir = dir
+}
+
+// returns the libraries that want a download of the given quality
+func (c Config) LibrariesFor(quality string) []LibraryConfig {
+	var libs []LibraryConfig
+	for _, lib := range c.AllLibraries() {
+		if lib.Accepts(quality) {
+			libs = append(libs, lib)
+		}
+	}
+	return libs
+}
+
+// returns every directory metadata should be synced into, primary first, without duplicates
+func (c Config) LibraryDirs(primary string) []string {
+	dirs := []string{}
+	seen := map[string]bool{}
+
+	for _, dir := range append([]string{primary}, libraryTargets(c.Libraries)...) {
+		if dir == "" {
+			continue
+		}
+		clean := filepath.Clean(dir)
+		if !seen[clean] {
+			seen[clean] = true
+			dirs = append(dirs, clean)
+		}
+	}
+
+	return dirs
+}
+
+// true if the library allows this quality. quality "n/a" or "" is accepted everywhere
+func (l LibraryConfig) Accepts(quality string) bool {
+	if len(l.Resolutions) == 0 || quality == "" || quality == "n/a" {
+		return true
+	}
+	return slices.Contains(l.Resolutions, quality)
+}
+
+func libraryTargets(libs []LibraryConfig) []string {
+	var dirs []string
+	for _, lib := range libs {
+		dirs = append(dirs, lib.TargetDir)
+	}
+	return dirs
+}
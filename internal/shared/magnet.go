@@ -0,0 +1,91 @@
+// shared/magnet.go
+package shared
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// IsMagnet reports whether s is a magnet URI
+func IsMagnet(s string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "magnet:")
+}
+
+// InfoHashFromMagnet returns the infohash of a magnet URI as lowercase hex, empty if it has none
+func InfoHashFromMagnet(uri string) string {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || !strings.EqualFold(u.Scheme, "magnet") {
+		return ""
+	}
+
+	for _, xt := range u.Query()["xt"] {
+		if len(xt) > 9 && strings.EqualFold(xt[:9], "urn:btih:") {
+			if hash := NormalizeInfoHash(xt[9:]); hash != "" {
+				return hash
+			}
+		}
+	}
+	return ""
+}
+
+// NormalizeInfoHash returns a hex or base32 infohash as lowercase hex, empty if it is not one
+func NormalizeInfoHash(s string) string {
+	s = strings.TrimSpace(s)
+
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err == nil {
+			return strings.ToLower(s)
+		}
+	case 32:
+		if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s)); err == nil {
+			return hex.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// MagnetFromInfoHash builds a bare magnet URI, peers are found through DHT
+func MagnetFromInfoHash(hash string) string {
+	return "magnet:?xt=urn:btih:" + hash
+}
+
+// IDFromInfoHash derives a local id for releases the indexer gave no numeric id.
+// Negative, so it never clashes with an indexer id. Downloads and the blocklist are keyed by it,
+// so it takes as much of the hash as an int holds: 63 bits, 31 on 32-bit platforms
+func IDFromInfoHash(hash string) int {
+	if len(hash) < 16 {
+		return 0
+	}
+	n, err := strconv.ParseUint(hash[:16], 16, 64)
+	if err != nil {
+		return 0
+	}
+	return -int(n>>(65-strconv.IntSize)) - 1
+}
+
+// Source returns what to hand a torrent client: the indexer's .torrent for releases with an indexer id, the magnet otherwise
+func (e TorrentEntry) Source(baseURL string) string {
+	return torrentSource(baseURL, e.TorrentID, e.Magnet, e.InfoHash)
+}
+
+// Source returns what to hand a torrent client, see TorrentEntry.Source
+func (td *TorrentDownload) Source(baseURL string) string {
+	return torrentSource(baseURL, td.TorrentID, td.Magnet, td.InfoHash)
+}
+
+func torrentSource(baseURL string, torrentID int, magnet, infoHash string) string {
+	switch {
+	case torrentID > 0:
+		return fmt.Sprintf("%s/download/%d.torrent", baseURL, torrentID)
+	case magnet != "":
+		return magnet
+	case infoHash != "":
+		return MagnetFromInfoHash(infoHash)
+	}
+	return fmt.Sprintf("%s/download/%d.torrent", baseURL, torrentID)
+}
@@ -0,0 +1,46 @@
+package shared
+
+import "testing"
+
+func TestInfoHashFromMagnet(t *testing.T) {
+	const hash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+	cases := map[string]string{
+		"magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=One+Pace": hash,
+		"magnet:?dn=x&xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK":                hash, // base32
+		"magnet:?xt=urn:btmh:1220abcd":                                             "",
+		"https://example.org/download/1.torrent":                                   "",
+	}
+	for uri, want := range cases {
+		if got := InfoHashFromMagnet(uri); got != want {
+			t.Errorf("InfoHashFromMagnet(%q) = %q, want %q", uri, got, want)
+		}
+	}
+
+	if id := IDFromInfoHash(hash); id >= 0 {
+		t.Errorf("IDFromInfoHash should be negative, got %d", id)
+	}
+
+	// hashes sharing their first 7 hex digits are different releases
+	if IDFromInfoHash("c12fe1c06bba254a9dc9f519b335aa7c1367a88a") == IDFromInfoHash("c12fe1c86bba254a9dc9f519b335aa7c1367a88a") {
+		t.Error("IDFromInfoHash collides on a shared prefix")
+	}
+	if id := IDFromInfoHash("ffffffffffffffffffffffffffffffffffffffff"); id >= 0 {
+		t.Errorf("IDFromInfoHash overflowed, got %d", id)
+	}
+}
+
+func TestTorrentSource(t *testing.T) {
+	const base = "https://example.org"
+	const hash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+	if got := (TorrentEntry{TorrentID: 12, Magnet: "magnet:?xt=urn:btih:" + hash}).Source(base); got != base+"/download/12.torrent" {
+		t.Errorf("indexer id should win, got %q", got)
+	}
+	if got := (TorrentEntry{TorrentID: IDFromInfoHash(hash), Magnet: "magnet:?xt=urn:btih:" + hash}).Source(base); got != "magnet:?xt=urn:btih:"+hash {
+		t.Errorf("expected magnet, got %q", got)
+	}
+	if got := (TorrentEntry{TorrentID: IDFromInfoHash(hash), InfoHash: hash}).Source(base); got != MagnetFromInfoHash(hash) {
+		t.Errorf("expected magnet from infohash, got %q", got)
+	}
+}
@@ -130,3 +130,23 @@ func NormalizeDash(s string) string {
 	// Replace en-dash and em-dash with hyphen-minus
 	return strings.NewReplacer("–", "-", "—", "-").Replace(s)
 }
+
+// parses a user supplied chapter or chapter range. "1000" -> 1000, 1000 and "990-1000" -> 990, 1000
+func ParseChapterQuery(q string) (int, int, error) {
+	q = strings.ReplaceAll(NormalizeDash(q), " ", "")
+
+	if n, err := strconv.Atoi(q); err == nil && n >= 0 {
+		return n, n, nil
+	}
+
+	parts := strings.Split(q, "-")
+	if len(parts) == 2 {
+		a, errA := strconv.Atoi(parts[0])
+		b, errB := strconv.Atoi(parts[1])
+		if errA == nil && errB == nil && a >= 0 && b >= a {
+			return a, b, nil
+		}
+	}
+
+	return -1, -1, fmt.Errorf("invalid chapter or range: %q", q)
+}
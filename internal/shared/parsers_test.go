@@ -65,3 +65,25 @@ func TestExtractChapterRangeFromNFO(t *testing.T) {
 		}
 	}
 }
+
+func TestParseChapterQuery(t *testing.T) {
+	tests := []struct {
+		input      string
+		start, end int
+		ok         bool
+	}{
+		{"1000", 1000, 1000, true},
+		{"990-1000", 990, 1000, true},
+		{"990 – 1000", 990, 1000, true},
+		{"1000-990", -1, -1, false},
+		{"abc", -1, -1, false},
+		{"", -1, -1, false},
+	}
+
+	for _, tc := range tests {
+		start, end, err := ParseChapterQuery(tc.input)
+		if start != tc.start || end != tc.end || (err == nil) != tc.ok {
+			t.Errorf("input %q: got (%d, %d, %v), want (%d, %d, ok=%v)", tc.input, start, end, err, tc.start, tc.end, tc.ok)
+		}
+	}
+}
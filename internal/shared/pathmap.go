@@ -0,0 +1,104 @@
+// shared/pathmap.go
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MapRemotePath translates a path reported by a torrent client to the local path, using the longest matching remote prefix.
+// Paths without a matching mapping are returned unchanged.
+func MapRemotePath(mappings []PathMapping, remotePath string) string {
+	best, bestLen, rest := -1, 0, ""
+
+	for i, m := range mappings {
+		remote := trimSeparators(m.Remote)
+		if remote == "" && !isRoot(m.Remote) {
+			continue
+		}
+
+		after, ok := cutPathPrefix(remotePath, remote)
+		if !ok || (best >= 0 && len(remote) <= bestLen) {
+			continue
+		}
+		best, bestLen, rest = i, len(remote), after
+	}
+
+	if best < 0 {
+		return remotePath
+	}
+
+	// the client may run on windows, its separators mean nothing here
+	rest = strings.ReplaceAll(rest, "\\", "/")
+	return filepath.Join(mappings[best].Local, filepath.FromSlash(rest))
+}
+
+// Validate checks the local side of a mapping is a readable directory
+func (m PathMapping) Validate() error {
+	if m.Remote == "" || m.Local == "" {
+		return fmt.Errorf("remote and local path are required")
+	}
+
+	info, err := os.Stat(m.Local)
+	if err != nil {
+		return fmt.Errorf("local path %s: %w", m.Local, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("local path %s is not a directory", m.Local)
+	}
+	if _, err := os.ReadDir(m.Local); err != nil {
+		return fmt.Errorf("local path %s is not readable: %w", m.Local, err)
+	}
+	return nil
+}
+
+// SetPathMapping adds a mapping, or changes the local path of an existing remote path
+func (c *Config) SetPathMapping(m PathMapping) {
+	for i, existing := range c.TorrentClient.PathMappings {
+		if trimSeparators(existing.Remote) == trimSeparators(m.Remote) {
+			c.TorrentClient.PathMappings[i] = m
+			return
+		}
+	}
+	c.TorrentClient.PathMappings = append(c.TorrentClient.PathMappings, m)
+}
+
+// RemovePathMapping removes the mapping for a remote path, false if there was none
+func (c *Config) RemovePathMapping(remote string) bool {
+	var kept []PathMapping
+	for _, m := range c.TorrentClient.PathMappings {
+		if trimSeparators(m.Remote) != trimSeparators(remote) {
+			kept = append(kept, m)
+		}
+	}
+
+	removed := len(kept) != len(c.TorrentClient.PathMappings)
+	c.TorrentClient.PathMappings = kept
+	return removed
+}
+
+// returns the part of path after prefix, if path is prefix or below it
+func cutPathPrefix(path, prefix string) (string, bool) {
+	if prefix == "" {
+		return strings.TrimLeft(path, `/\`), true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+
+	rest := path[len(prefix):]
+	if rest != "" && rest[0] != '/' && rest[0] != '\\' {
+		return "", false // e.g /downloads2 is not below /downloads
+	}
+	return strings.TrimLeft(rest, `/\`), true
+}
+
+func trimSeparators(p string) string {
+	return strings.TrimRight(p, `/\`)
+}
+
+func isRoot(p string) bool {
+	return p != "" && trimSeparators(p) == ""
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMapRemotePath(t *testing.T) {
+	mappings := []PathMapping{
+		{Remote: "/downloads", Local: "/data/torrents"},
+		{Remote: "/downloads/onepace/", Local: "/mnt/onepace"},
+		{Remote: `D:\Torrents`, Local: "/data/windows"},
+	}
+
+	cases := map[string]string{
+		"/downloads/Arc 1":           "/data/torrents/Arc 1",
+		"/downloads":                 "/data/torrents",
+		"/downloads/onepace/Arc 2":   "/mnt/onepace/Arc 2", // longest prefix wins
+		"/downloads2/Arc 3":          "/downloads2/Arc 3",  // not below /downloads
+		`D:\Torrents\One Pace\Arc 4`: "/data/windows/One Pace/Arc 4",
+		"/elsewhere/Arc 5":           "/elsewhere/Arc 5",
+	}
+	for remote, want := range cases {
+		if got := MapRemotePath(mappings, remote); got != filepath.FromSlash(want) {
+			t.Errorf("MapRemotePath(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
+
+func TestPathMappingValidate(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := (PathMapping{Remote: "/downloads", Local: dir}).Validate(); err != nil {
+		t.Errorf("expected valid mapping: %v", err)
+	}
+	if err := (PathMapping{Remote: "/downloads", Local: filepath.Join(dir, "missing")}).Validate(); err == nil {
+		t.Error("expected error for missing local path")
+	}
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0644)
+	if err := (PathMapping{Remote: "/downloads", Local: file}).Validate(); err == nil {
+		t.Error("expected error for a file")
+	}
+}
@@ -0,0 +1,106 @@
+// shared/schedule.go
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DefaultMaxConcurrent = 5
+
+// downloads running at once
+func (c ScheduleConfig) Concurrent() int {
+	if c.MaxConcurrent <= 0 {
+		return DefaultMaxConcurrent
+	}
+	return c.MaxConcurrent
+}
+
+// Validate checks every window is a valid "HH:MM-HH:MM"
+func (c ScheduleConfig) Validate() error {
+	for _, w := range c.Windows {
+		if _, _, err := ParseWindow(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open reports whether downloads may start at now. No windows means always.
+func (c ScheduleConfig) Open(now time.Time) bool {
+	if len(c.Windows) == 0 {
+		return true
+	}
+
+	at := sinceMidnight(now)
+	for _, w := range c.Windows {
+		start, end, err := ParseWindow(w)
+		if err != nil {
+			continue
+		}
+		switch {
+		case start == end:
+			return true
+		case start < end && at >= start && at < end:
+			return true
+		case start > end && (at >= start || at < end): // crosses midnight, e.g 22:00-06:00
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the next window opens, now if one is open. Zero if no window is valid.
+func (c ScheduleConfig) NextOpen(now time.Time) time.Time {
+	if c.Open(now) {
+		return now
+	}
+
+	var next time.Time
+	for _, w := range c.Windows {
+		start, _, err := ParseWindow(w)
+		if err != nil {
+			continue
+		}
+		at := time.Date(now.Year(), now.Month(), now.Day(), int(start/time.Hour), int(start%time.Hour/time.Minute), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// ParseWindow parses "01:00-07:00" into offsets from midnight
+func ParseWindow(w string) (time.Duration, time.Duration, error) {
+	from, to, ok := strings.Cut(w, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", w)
+	}
+
+	start, err := parseClock(from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid window %q: %w", w, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid window %q: %w", w, err)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// wall clock time of day, so windows follow DST changes
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleWindows(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 1, 1, h, m, 0, 0, time.UTC) }
+
+	night := ScheduleConfig{Windows: []string{"01:00-07:00"}}
+	cases := []struct {
+		now  time.Time
+		open bool
+	}{
+		{at(0, 59), false},
+		{at(1, 0), true},
+		{at(6, 59), true},
+		{at(7, 0), false},
+		{at(23, 0), false},
+	}
+	for _, c := range cases {
+		if got := night.Open(c.now); got != c.open {
+			t.Errorf("Open(%s) = %v, want %v", c.now.Format("15:04"), got, c.open)
+		}
+	}
+
+	if next := night.NextOpen(at(12, 0)); !next.Equal(at(1, 0).AddDate(0, 0, 1)) {
+		t.Errorf("NextOpen(12:00) = %v", next)
+	}
+	if next := night.NextOpen(at(0, 30)); !next.Equal(at(1, 0)) {
+		t.Errorf("NextOpen(00:30) = %v", next)
+	}
+
+	// crossing midnight
+	late := ScheduleConfig{Windows: []string{"22:00-06:00"}}
+	if !late.Open(at(23, 30)) || !late.Open(at(5, 0)) || late.Open(at(12, 0)) {
+		t.Error("22:00-06:00 should be open from 22:00 until 06:00")
+	}
+
+	if !(ScheduleConfig{}).Open(at(12, 0)) {
+		t.Error("no windows should always be open")
+	}
+
+	if err := (ScheduleConfig{Windows: []string{"1am-7am"}}).Validate(); err == nil {
+		t.Error("expected invalid window error")
+	}
+}
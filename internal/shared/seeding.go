@@ -0,0 +1,91 @@
+// shared/seeding.go
+package shared
+
+import (
+	"fmt"
+	"time"
+)
+
+// used when seeding is enabled without any target
+const DefaultSeedRatio = 1.0
+
+// external clients seed to this ratio when no target is set, then the torrent is removed
+const DefaultClientSeedRatio = 0.6
+
+// seeding modes, which targets have to be reached
+const (
+	SeedModeAny   = ""      // whichever target is reached first
+	SeedModeRatio = "ratio" // only the ratio counts
+	SeedModeTime  = "time"  // only the seed time counts
+	SeedModeBoth  = "both"  // ratio and seed time
+)
+
+// reports whether a torrent has seeded enough. targets set to 0 are ignored
+func (c SeedingConfig) Reached(uploaded, size int64, seeded time.Duration) bool {
+	return c.reached(uploaded, size, seeded, DefaultSeedRatio)
+}
+
+// ClientReached is Reached for torrents in an external client, which default to a lower ratio
+func (c SeedingConfig) ClientReached(uploaded, size int64, seeded time.Duration) bool {
+	return c.reached(uploaded, size, seeded, DefaultClientSeedRatio)
+}
+
+// ClientLimits are the targets an external client can stop seeding at by itself.
+// clients stop at the first target, so both mode sets none and leaves it to the worker
+func (c SeedingConfig) ClientLimits() (ratio float64, minutes int) {
+	if c.Mode == SeedModeBoth {
+		return 0, 0
+	}
+	return c.targets(DefaultClientSeedRatio)
+}
+
+// the targets that count in this mode, the default ratio if none do
+func (c SeedingConfig) targets(defaultRatio float64) (ratio float64, minutes int) {
+	ratio, minutes = c.Ratio, c.Minutes
+	switch c.Mode {
+	case SeedModeRatio:
+		minutes = 0
+	case SeedModeTime:
+		ratio = 0
+	}
+	if ratio <= 0 && minutes <= 0 {
+		ratio = defaultRatio
+	}
+	return ratio, minutes
+}
+
+func (c SeedingConfig) reached(uploaded, size int64, seeded time.Duration, defaultRatio float64) bool {
+	ratio, minutes := c.targets(defaultRatio)
+
+	ratioReached := ratio > 0 && size > 0 && float64(uploaded)/float64(size) >= ratio
+	timeReached := minutes > 0 && seeded >= time.Duration(minutes)*time.Minute
+
+	if c.Mode == SeedModeBoth && ratio > 0 && minutes > 0 {
+		return ratioReached && timeReached
+	}
+	return ratioReached || timeReached
+}
+
+// Validate checks the mode is known and has the targets it needs
+func (c SeedingConfig) Validate() error {
+	if c.Ratio < 0 || c.Minutes < 0 {
+		return fmt.Errorf("seeding targets can't be negative")
+	}
+
+	switch c.Mode {
+	case SeedModeAny, SeedModeRatio:
+		return nil
+	case SeedModeTime:
+		if c.Minutes <= 0 {
+			return fmt.Errorf("time mode needs a minutes target")
+		}
+		return nil
+	case SeedModeBoth:
+		if c.Ratio <= 0 || c.Minutes <= 0 {
+			return fmt.Errorf("both mode needs a ratio and a minutes target")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown seeding mode %q, use ratio, time or both", c.Mode)
+	}
+}
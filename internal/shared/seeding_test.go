@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeedingReached(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		name     string
+		cfg      SeedingConfig
+		uploaded int64
+		seeded   time.Duration
+		want     bool
+	}{
+		{"default ratio not reached", SeedingConfig{}, 999, time.Hour, false},
+		{"default ratio reached", SeedingConfig{}, 1000, 0, true},
+		{"ratio reached", SeedingConfig{Ratio: 2}, 2000, 0, true},
+		{"ratio not reached", SeedingConfig{Ratio: 2}, 1999, 0, false},
+		{"time only ignores ratio", SeedingConfig{Minutes: 30}, 5000, 29 * time.Minute, false},
+		{"time reached", SeedingConfig{Minutes: 30}, 0, 30 * time.Minute, true},
+		{"first target wins", SeedingConfig{Ratio: 5, Minutes: 10}, 0, 10 * time.Minute, true},
+		{"ratio mode ignores time", SeedingConfig{Ratio: 2, Minutes: 10, Mode: SeedModeRatio}, 0, time.Hour, false},
+		{"time mode ignores ratio", SeedingConfig{Ratio: 2, Minutes: 10, Mode: SeedModeTime}, 5000, time.Minute, false},
+		{"both needs ratio", SeedingConfig{Ratio: 2, Minutes: 10, Mode: SeedModeBoth}, 1999, time.Hour, false},
+		{"both needs time", SeedingConfig{Ratio: 2, Minutes: 10, Mode: SeedModeBoth}, 5000, 9 * time.Minute, false},
+		{"both reached", SeedingConfig{Ratio: 2, Minutes: 10, Mode: SeedModeBoth}, 2000, 10 * time.Minute, true},
+	}
+
+	for _, tc := range tests {
+		if got := tc.cfg.Reached(tc.uploaded, size, tc.seeded); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSeedingClientDefault(t *testing.T) {
+	if !(SeedingConfig{}).ClientReached(600, 1000, 0) {
+		t.Error("external clients should default to a 0.6 ratio")
+	}
+	if (SeedingConfig{Ratio: 1}).ClientReached(600, 1000, 0) {
+		t.Error("a configured ratio should override the client default")
+	}
+
+	if ratio, minutes := (SeedingConfig{Ratio: 2, Minutes: 30, Mode: SeedModeTime}).ClientLimits(); ratio != 0 || minutes != 30 {
+		t.Errorf("time mode limits = %v, %v", ratio, minutes)
+	}
+	if ratio, minutes := (SeedingConfig{Ratio: 2, Minutes: 30, Mode: SeedModeBoth}).ClientLimits(); ratio != 0 || minutes != 0 {
+		t.Errorf("both mode should leave the client unlimited, got %v, %v", ratio, minutes)
+	}
+}
+
+func TestSeedingValidate(t *testing.T) {
+	valid := []SeedingConfig{{}, {Ratio: 1, Mode: SeedModeRatio}, {Minutes: 5, Mode: SeedModeTime}, {Ratio: 1, Minutes: 5, Mode: SeedModeBoth}}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", c, err)
+		}
+	}
+
+	invalid := []SeedingConfig{{Mode: "sometimes"}, {Mode: SeedModeTime}, {Ratio: 1, Mode: SeedModeBoth}, {Ratio: -1}}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
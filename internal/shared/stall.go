@@ -0,0 +1,84 @@
+// shared/stall.go
+package shared
+
+import "time"
+
+const (
+	defaultStallMinutes  = 10
+	defaultNoPeerMinutes = 3
+	defaultMaxAttempts   = 3
+)
+
+func (c RetryConfig) StallWindow() time.Duration {
+	if c.StallMinutes <= 0 {
+		return defaultStallMinutes * time.Minute
+	}
+	return time.Duration(c.StallMinutes) * time.Minute
+}
+
+func (c RetryConfig) NoPeerWindow() time.Duration {
+	if c.NoPeerMinutes <= 0 {
+		return defaultNoPeerMinutes * time.Minute
+	}
+	return time.Duration(c.NoPeerMinutes) * time.Minute
+}
+
+func (c RetryConfig) Attempts() int {
+	if c.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return c.MaxAttempts
+}
+
+// StallDetector flags a download that made no progress over a sliding window, or had no peers for a while
+type StallDetector struct {
+	window       time.Duration
+	noPeerWindow time.Duration
+	started      time.Time
+	lastPeer     time.Time
+	samples      []progressSample
+}
+
+type progressSample struct {
+	at    time.Time
+	bytes int64
+}
+
+func NewStallDetector(cfg RetryConfig, now time.Time) *StallDetector {
+	return &StallDetector{
+		window:       cfg.StallWindow(),
+		noPeerWindow: cfg.NoPeerWindow(),
+		started:      now,
+		lastPeer:     now,
+	}
+}
+
+// records downloaded bytes and connected peers
+func (d *StallDetector) Observe(now time.Time, bytes int64, peers int) {
+	if peers > 0 {
+		d.lastPeer = now
+	}
+
+	d.samples = append(d.samples, progressSample{at: now, bytes: bytes})
+
+	// keep the newest sample older than the window, progress is measured from there
+	cutoff := now.Add(-d.window)
+	drop := 0
+	for drop+1 < len(d.samples) && !d.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	d.samples = d.samples[drop:]
+}
+
+func (d *StallDetector) Stalled(now time.Time) bool {
+	if now.Sub(d.lastPeer) >= d.noPeerWindow {
+		return true
+	}
+
+	if now.Sub(d.started) < d.window || len(d.samples) == 0 {
+		return false
+	}
+
+	first, last := d.samples[0], d.samples[len(d.samples)-1]
+	return now.Sub(first.at) >= d.window && last.bytes <= first.bytes
+}
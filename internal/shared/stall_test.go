@@ -0,0 +1,41 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStallDetector(t *testing.T) {
+	cfg := RetryConfig{StallMinutes: 10, NoPeerMinutes: 3}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return start.Add(time.Duration(min) * time.Minute) }
+
+	// steady progress is never a stall
+	d := NewStallDetector(cfg, start)
+	for m := 0; m <= 30; m++ {
+		d.Observe(at(m), int64(m*100), 5)
+		if d.Stalled(at(m)) {
+			t.Fatalf("steady download stalled at minute %d", m)
+		}
+	}
+
+	// progress stops at minute 5, stalled once the whole window is flat
+	d = NewStallDetector(cfg, start)
+	for m := 0; m <= 20; m++ {
+		bytes := int64(min(m, 5) * 100)
+		d.Observe(at(m), bytes, 2)
+		stalled := d.Stalled(at(m))
+		if want := m >= 15; stalled != want {
+			t.Fatalf("minute %d: stalled=%v, want %v", m, stalled, want)
+		}
+	}
+
+	// no peers trips the shorter window
+	d = NewStallDetector(cfg, start)
+	for m := 0; m <= 3; m++ {
+		d.Observe(at(m), 0, 0)
+	}
+	if !d.Stalled(at(3)) {
+		t.Fatal("expected stall without peers")
+	}
+}
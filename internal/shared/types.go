@@ -6,10 +6,52 @@ import "time"
 
 // config file
 type Config struct {
-	TargetDir     string              `json:"target_dir"`
-	GitHubRepo    string              `json:"github_base_url"`
-	Source        ScraperConfig       `json:"source"`
-	TorrentClient TorrentClientConfig `json:"torrent_client"`
+	TargetDir     string                `json:"target_dir"`
+	GitHubRepo    string                `json:"github_base_url"`
+	Source        ScraperConfig         `json:"source"`
+	TorrentClient TorrentClientConfig   `json:"torrent_client"`
+	Clients       []TorrentClientConfig `json:"torrent_clients,omitempty"` // more external clients, picked by priority and rules
+	Libraries     []LibraryConfig       `json:"libraries,omitempty"`
+	Seeding       SeedingConfig         `json:"seeding"`
+	Retry         RetryConfig           `json:"retry"`
+	Schedule      ScheduleConfig        `json:"schedule"`
+	Disk          DiskConfig            `json:"disk"`
+}
+
+// when and how many downloads run. queued downloads stay pending until a window opens
+type ScheduleConfig struct {
+	MaxConcurrent int      `json:"max_concurrent,omitempty"` // downloads at once, default 5
+	Windows       []string `json:"windows,omitempty"`        // e.g ["01:00-07:00"], local time. empty allows any time
+}
+
+// free space kept on the download and library disks. 0 uses the default
+type DiskConfig struct {
+	ReserveMB int64 `json:"reserve_mb,omitempty"` // downloads that would leave less free don't start, default 1024
+	WarnMB    int64 `json:"warn_mb,omitempty"`    // less free shows a low space warning, default 10240
+}
+
+// stall detection and fallback to other releases of the same chapters. 0 uses the default
+type RetryConfig struct {
+	StallMinutes  int `json:"stall_minutes,omitempty"`   // no progress for this long is a stall, default 10
+	NoPeerMinutes int `json:"no_peer_minutes,omitempty"` // no peers for this long is a stall, default 3
+	MaxAttempts   int `json:"max_attempts,omitempty"`    // releases tried per download, default 3
+}
+
+// seeding policy. Enabled turns on seeding for the internal client, external clients always seed
+// until the policy is reached and the torrent is removed
+type SeedingConfig struct {
+	Enabled bool    `json:"enabled"`
+	Ratio   float64 `json:"ratio,omitempty"`   // upload/size target, 0 disables
+	Minutes int     `json:"minutes,omitempty"` // seed time target, 0 disables
+	Mode    string  `json:"mode,omitempty"`    // ratio, time or both, empty stops at whichever target is reached first
+}
+
+// a named media library. TargetDir in Config stays the primary library, where the metadata index lives
+type LibraryConfig struct {
+	Name        string   `json:"name"`
+	TargetDir   string   `json:"target_dir"`
+	Resolutions []string `json:"resolutions,omitempty"` // e.g ["1080p"], empty accepts all
+	Placement   string   `json:"placement,omitempty"`   // hardlink (default), copy or symlink
 }
 
 type TorrentClientConfig struct {
@@ -17,6 +59,43 @@ type TorrentClientConfig struct {
 	URL      string `json:"url"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// only used with more than one client
+	Name     string      `json:"name,omitempty"`     // unique, the type if empty
+	Priority int         `json:"priority,omitempty"` // lower is preferred
+	Rules    ClientRules `json:"rules,omitempty"`
+
+	// external clients only
+	Category       string        `json:"category,omitempty"`        // qBittorrent category, Deluge/Transmission label. default OnePace
+	UploadTorrents bool          `json:"upload_torrents,omitempty"` // fetch .torrent files here and upload them, for clients without a route to the indexer
+	PathMappings   []PathMapping `json:"path_mappings,omitempty"`   // client paths -> local paths, for clients in another container or machine
+	SavePath       string        `json:"save_path,omitempty"`       // download dir as the client sees it, empty uses the client's default
+	AddPaused      bool          `json:"add_paused,omitempty"`      // add torrents paused, to start them by hand
+	Sequential     bool          `json:"sequential,omitempty"`      // download pieces in order, not supported by Deluge 1.x or Transmission before 4.1
+
+	// blackhole only
+	WatchDir     string `json:"watch_dir,omitempty"`     // .torrent and .magnet files are written here
+	CompletedDir string `json:"completed_dir,omitempty"` // where the other client puts finished downloads
+
+	// internal client only
+	IncompleteDir string `json:"incomplete_dir,omitempty"` // partial downloads, default in the user cache dir
+	ListenPort    int    `json:"listen_port,omitempty"`    // 0 picks a random port
+	DownloadLimit int    `json:"download_limit,omitempty"` // KiB/s, 0 is unlimited
+	UploadLimit   int    `json:"upload_limit,omitempty"`   // KiB/s, 0 is unlimited
+}
+
+// which releases a client takes, empty accepts all
+type ClientRules struct {
+	Resolutions []string `json:"resolutions,omitempty"` // e.g ["1080p"]
+	MinSizeMB   int64    `json:"min_size_mb,omitempty"` // releases of unknown size pass size rules
+	MaxSizeMB   int64    `json:"max_size_mb,omitempty"`
+	Bundles     string   `json:"bundles,omitempty"` // "only" for whole arc releases, "never" for the rest, empty for both
+}
+
+// a client path prefix and where it is mounted locally, e.g /downloads -> /data/torrents
+type PathMapping struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
 }
 
 // scrape config
@@ -35,7 +114,10 @@ type ScraperFields struct {
 	Seeders     string `json:"seeders"`
 	TorrentLink string `json:"torrent_link"`
 	TorrentID   string `json:"torrent_id"`
+	Magnet      string `json:"magnet,omitempty"`    // selector for a magnet link, for indexers without .torrent files
+	InfoHash    string `json:"info_hash,omitempty"` // selector for an infohash, used when the row has no magnet
 	UploadDate  string `json:"upload_date"`
+	Size        string `json:"size,omitempty"` // selector for the release size, e.g "1.2 GiB"
 }
 
 type ValidationConfig struct {
@@ -44,7 +126,9 @@ type ValidationConfig struct {
 
 // Index maps seasons
 type MetadataIndex struct {
-	Seasons map[string]SeasonIndex `json:"seasons"`
+	Seasons  map[string]SeasonIndex `json:"seasons"`
+	Artwork  Artwork                `json:"artwork,omitempty"` // show artwork
+	Chapters *ChapterIndex          `json:"-"`                 // interval lookup, built alongside the index
 }
 
 // seasons maps episodes
@@ -55,6 +139,14 @@ type SeasonIndex struct {
 	EpisodeRange map[string]EpisodeData `json:"episodes"`
 	Quality      string                 `json:"quality,omitempty"`
 	Language     string                 `json:"language,omitempty"`
+	Artwork      Artwork                `json:"artwork,omitempty"`
+}
+
+// image paths, relative to the target directory
+type Artwork struct {
+	Poster string `json:"poster,omitempty"`
+	Fanart string `json:"fanart,omitempty"`
+	Banner string `json:"banner,omitempty"`
 }
 
 // episodes maps titles and have
@@ -62,23 +154,53 @@ type EpisodeData struct {
 	Title string `json:"title"`
 }
 
+// local fixes applied on top of upstream metadata, stored next to metadata-index.json
+type MetadataOverrides struct {
+	Seasons  map[string]SeasonOverride  `json:"seasons,omitempty"`  // keyed by season folder, e.g "Season 5"
+	Episodes map[string]EpisodeOverride `json:"episodes,omitempty"` // keyed by episode file name without .nfo
+}
+
+// per-season override fields
+type SeasonOverride struct {
+	Name string `json:"name,omitempty"`
+}
+
+// per-episode override fields
+type EpisodeOverride struct {
+	ChapterRange string `json:"chapter_range,omitempty"`
+	Title        string `json:"title,omitempty"`
+}
+
 // download struct
 type TorrentDownload struct {
-	Title             string    // title for display
-	FullTitle         string    // full torrent title
-	TorrentID         int       // torrentID for tempdir
-	ChapterRange      string    // Main
-	Started           time.Time // time torrent started (unused?)
-	Progress          int64     // used by ui progressbar
-	TotalSize         int64     // used by ui progress bar
-	PlacementFull     []string  // used to display placed messages after all placements are done
-	PlacementProgress string    //used for placement messages after download is done
-	Done              bool      // set to true when torrent is downloaded
-	Placed            bool      // set to true when files are placed, before clearing active downloads
-	ExternalHash      string    // hash from external torrent client
-	UseExternal       bool      // whether this download uses external client
-	SavePath          string    // path where torrent client saved files
-	Imported          bool      // set to true when files are successfully imported/hardlinked
+	Title             string            // title for display
+	FullTitle         string            // full torrent title
+	TorrentID         int               // torrentID for tempdir
+	ChapterRange      string            // Main
+	Started           time.Time         // time torrent started (unused?)
+	Progress          int64             // used by ui progressbar
+	TotalSize         int64             // used by ui progress bar
+	PlacementFull     []string          // used to display placed messages after all placements are done
+	PlacementProgress string            //used for placement messages after download is done
+	Done              bool              // set to true when torrent is downloaded
+	Placed            bool              // set to true when files are placed, before clearing active downloads
+	ExternalHash      string            // hash from external torrent client
+	UseExternal       bool              // whether this download uses external client
+	SavePath          string            // path where torrent client saved files
+	Imported          bool              // set to true when files are successfully imported/hardlinked
+	Quality           string            // parsed quality, used to pick libraries
+	PlacedFiles       map[string]string // torrent file path -> placed library file, used for seeding
+	Attempt           int               // releases tried for this chapter range, 0 for the first
+	SelectedFiles     []string          // torrent file paths being downloaded, nil means all
+	FilesSelected     bool              // file selection was applied, external clients only
+	Pending           bool              // waiting for a download window or a free slot
+	TorrentURL        string            // external downloads not yet added to the client
+	Magnet            string            // magnet uri, for releases without an indexer id
+	InfoHash          string            // lowercase hex, known once the torrent was added
+	Client            string            // name of the external client that has it, empty for the primary one
+	Bundle            bool              // the release is a whole arc, for client rules
+	AddedToClient     bool              // the client didn't have the torrent before, so opforjellyfin may remove it
+	PausedBySchedule  bool              // paused in the external client when the download window closed
 }
 
 // entry for dl
@@ -90,11 +212,15 @@ type TorrentEntry struct {
 	Seeders       int    // number of seeders
 	RawIndex      int    // RawIndex is based on ChapterRange, used for placement
 	TorrentLink   string // torrent link
-	TorrentID     int    // torrent ID, extracted from link
+	TorrentID     int    // torrent ID, extracted from link. derived from the infohash and negative if the indexer has none
+	Magnet        string // magnet uri, if the indexer publishes one
+	InfoHash      string // lowercase hex infohash, from the magnet or the indexer
 	ChapterRange  string // torrent chapter range
 	MetaDataAvail bool   // metadata matching chapter range exists
 	IsSpecial     bool   // is a special (no chapter range)
 	HaveIt        int    // video with same chapter range exists
 	Date          string //
 	IsExtended    bool   // extended version
+	Size          int64  // bytes, 0 if the indexer doesn't show it
+	IsBundle      bool   // covers a whole arc
 }
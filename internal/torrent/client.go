@@ -0,0 +1,253 @@
+// torrent/client.go
+package torrent
+
+import (
+	"fmt"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"golang.org/x/time/rate"
+)
+
+const (
+	lockFileName   = ".opfor.lock"
+	lockHeartbeat  = 30 * time.Second
+	lockStaleAfter = 2 * time.Minute
+
+	// the client reads and writes up to a burst at once, a small one would stall every chunk
+	minRateBurst = 256 << 10
+)
+
+// one internal client per process, shared by downloads and the seeder
+var (
+	clientMu      sync.Mutex
+	sharedClient  *torrent.Client
+	clientRefs    int
+	clientDir     string // incomplete dir in use, may be a private one if another process holds the lock
+	clientStorage storage.ClientImplCloser
+	stopHeartbeat chan struct{}
+)
+
+// IncompleteDir returns where the internal client keeps partial downloads
+func IncompleteDir(cfg shared.Config) string {
+	if cfg.TorrentClient.IncompleteDir != "" {
+		return cfg.TorrentClient.IncompleteDir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "opforjellyfin", "incomplete")
+}
+
+// AcquireClient returns the shared internal client, starting it on first use. Every call needs a ReleaseClient.
+func AcquireClient(cfg shared.Config) (*torrent.Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if sharedClient != nil {
+		clientRefs++
+		return sharedClient, nil
+	}
+
+	dir := IncompleteDir(cfg)
+	if err := shared.CreateDirectory(dir); err != nil {
+		return nil, fmt.Errorf("could not create incomplete dir: %w", err)
+	}
+
+	// another process downloads into dir, use a private one instead of fighting over files
+	locked := lockDir(dir)
+	if !locked {
+		dir = filepath.Join(dir, fmt.Sprintf(".proc-%d", os.Getpid()))
+		logger.Log(false, "Incomplete dir is in use by another process, using %s", dir)
+		if err := shared.CreateDirectory(dir); err != nil {
+			return nil, fmt.Errorf("could not create incomplete dir: %w", err)
+		}
+	}
+
+	// piece completion survives restarts, so interrupted downloads resume
+	completion, err := storage.NewDefaultPieceCompletionForDir(dir)
+	if err != nil {
+		logger.Log(false, "Could not open piece completion in %s, downloads will be rehashed: %v", dir, err)
+		completion = storage.NewMapPieceCompletion()
+	}
+
+	// every torrent gets its own folder, named by infohash
+	store := storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir: dir,
+		TorrentDirMaker: func(baseDir string, info *metainfo.Info, infoHash metainfo.Hash) string {
+			return filepath.Join(baseDir, infoHash.HexString())
+		},
+		PieceCompletion: completion,
+	})
+
+	tcfg := torrent.NewDefaultClientConfig()
+	tcfg.DataDir = dir
+	tcfg.DefaultStorage = store
+	tcfg.ListenPort = cfg.TorrentClient.ListenPort
+	tcfg.Seed = cfg.Seeding.Enabled
+	tcfg.NoUpload = !cfg.Seeding.Enabled
+	if limiter := rateLimiter(cfg.TorrentClient.DownloadLimit); limiter != nil {
+		tcfg.DownloadRateLimiter = limiter
+	}
+	if limiter := rateLimiter(cfg.TorrentClient.UploadLimit); limiter != nil {
+		tcfg.UploadRateLimiter = limiter
+	}
+
+	client, err := torrent.NewClient(tcfg)
+	if err != nil {
+		store.Close()
+		if locked {
+			unlockDir(dir)
+		}
+		return nil, err
+	}
+
+	sharedClient = client
+	clientStorage = store
+	clientDir = dir
+	clientRefs = 1
+	stopHeartbeat = make(chan struct{})
+	if locked {
+		go heartbeat(dir, stopHeartbeat)
+	}
+
+	logger.Log(false, "Started internal torrent client in %s", dir)
+	return sharedClient, nil
+}
+
+// ReleaseClient closes the shared client once the last user let go of it
+func ReleaseClient() {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if sharedClient == nil {
+		return
+	}
+
+	clientRefs--
+	if clientRefs > 0 {
+		return
+	}
+
+	closeWithLogs(sharedClient)
+	clientStorage.Close()
+	close(stopHeartbeat)
+
+	// private dirs can't be resumed by anyone, don't leave them around
+	if strings.HasPrefix(filepath.Base(clientDir), ".proc-") {
+		os.RemoveAll(clientDir)
+	} else {
+		unlockDir(clientDir)
+	}
+
+	sharedClient = nil
+	clientStorage = nil
+	clientDir = ""
+}
+
+// limiter for a KiB/s limit, nil if unlimited
+func rateLimiter(kib int) *rate.Limiter {
+	if kib <= 0 {
+		return nil
+	}
+	bytes := kib << 10
+	return rate.NewLimiter(rate.Limit(bytes), max(bytes, minRateBurst))
+}
+
+// dir a torrent is downloaded into
+func downloadDir(t *torrent.Torrent) string {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	return filepath.Join(clientDir, t.InfoHash().HexString())
+}
+
+// ClearIncomplete removes all partial downloads. Fails if another process is using them.
+func ClearIncomplete(cfg shared.Config) error {
+	dir := IncompleteDir(cfg)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	clientMu.Lock()
+	running := sharedClient != nil
+	clientMu.Unlock()
+
+	if running || !lockDir(dir) {
+		return fmt.Errorf("incomplete dir %s is in use", dir)
+	}
+	defer unlockDir(dir)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Name() == lockFileName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takes the lock on dir, unless another live process holds it
+func lockDir(dir string) bool {
+	path := filepath.Join(dir, lockFileName)
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < lockStaleAfter {
+		data, _ := os.ReadFile(path)
+		if pid, _ := strconv.Atoi(strings.TrimSpace(string(data))); pid != os.Getpid() {
+			return false
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		logger.Log(false, "Could not write lock %s: %v", path, err)
+	}
+	return true
+}
+
+func unlockDir(dir string) {
+	path := filepath.Join(dir, lockFileName)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if pid, _ := strconv.Atoi(strings.TrimSpace(string(data))); pid == os.Getpid() {
+		os.Remove(path)
+	}
+}
+
+// keeps the lock fresh, a lock that isn't touched for lockStaleAfter belongs to a dead process
+func heartbeat(dir string, stop chan struct{}) {
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+
+	path := filepath.Join(dir, lockFileName)
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(path, now, now); err != nil {
+				logger.Log(false, "Could not refresh lock %s: %v", path, err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// torrent/fallback.go
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/scraper"
+	"opforjellyfin/internal/shared"
+)
+
+// DownloadWithFallback downloads td, switching to the next best release of the same chapters when it stalls.
+// Stalled releases are blocklisted. td ends up pointing at the release that was tried last.
+func DownloadWithFallback(ctx context.Context, td *shared.TorrentDownload, cfg shared.Config, title func(shared.TorrentEntry) string) error {
+	var candidates []shared.TorrentEntry
+	tried := make(map[int]bool)
+
+	for {
+		tried[td.TorrentID] = true
+
+		err := StartTorrent(ctx, td)
+		if !errors.Is(err, ErrStalled) {
+			return err
+		}
+
+		logger.Log(true, "⏸️  %s stalled", td.Title)
+		if err := shared.BlockRelease(td.TorrentID, td.FullTitle, "stalled"); err != nil {
+			logger.Log(false, "Could not blocklist %s: %v", td.FullTitle, err)
+		}
+		removeSeedJob(td.TorrentID)
+
+		if td.Attempt+1 >= cfg.Retry.Attempts() {
+			return fmt.Errorf("%w after %d release(s)", ErrStalled, td.Attempt+1)
+		}
+
+		if candidates == nil {
+			candidates, err = scraper.FetchTorrents(cfg)
+			if err != nil {
+				return fmt.Errorf("%w, could not look for alternatives: %v", ErrStalled, err)
+			}
+		}
+
+		alt := scraper.FindAlternative(candidates, td.ChapterRange, td.Quality, tried)
+		if alt == nil {
+			return fmt.Errorf("%w, no other release for %s", ErrStalled, td.ChapterRange)
+		}
+
+		logger.Log(true, "🔁 Switching to %s (%s, %d seeders)", alt.Title, alt.Quality, alt.Seeders)
+		td.SwitchRelease(*alt, title(*alt))
+	}
+}
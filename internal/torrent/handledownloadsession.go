@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/matcher"
@@ -10,15 +11,16 @@ import (
 	"opforjellyfin/internal/ui"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 )
 
-func HandleDownloadSession(entries []shared.TorrentEntry, outDir string) {
-	// based on tests
-	const maxConcurrent = 5
+func HandleDownloadSession(entries []shared.TorrentEntry, cfg shared.Config) {
+	maxConcurrent := cfg.Schedule.Concurrent()
+	if err := cfg.Schedule.Validate(); err != nil {
+		logger.Log(true, "⚠️  Schedule: %v", err)
+	}
 
 	// Create a context that can be cancelled with Ctrl+C
 	ctx, cancel := context.WithCancel(context.Background())
@@ -39,18 +41,21 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string) {
 	// Prepare all download metadata first
 	allTDs := []*shared.TorrentDownload{}
 	for _, entry := range entries {
-		dKey := ui.StyleFactory(fmt.Sprintf("%4d", entry.DownloadKey), ui.Style.Pink)
-		title := ui.StyleFactory(entry.TorrentName, ui.Style.LBlue)
-
 		td := &shared.TorrentDownload{
-			Title:        fmt.Sprintf("%s: %s (%s)", dKey, title, entry.Quality),
+			Title:        sessionTitle(entry),
 			TorrentID:    entry.TorrentID,
 			FullTitle:    entry.Title,
 			Started:      time.Now(),
 			ChapterRange: entry.ChapterRange,
+			Quality:      entry.Quality,
+			Magnet:       entry.Magnet,
+			InfoHash:     entry.InfoHash,
 		}
 
 		shared.SaveTorrentDownload(td)
+		if !cfg.Schedule.Open(time.Now()) {
+			td.MarkPending("Waiting for download window")
+		}
 		allTDs = append(allTDs, td)
 	}
 
@@ -81,15 +86,30 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string) {
 				default:
 					td := allTDs[i]
 
-					// Download
-					downloadCtx, downloadCancel := context.WithTimeout(ctx, 30*time.Minute)
-					err := StartTorrent(downloadCtx, td)
-					downloadCancel()
+					if err := waitForWindow(ctx, td, cfg.Schedule); err != nil {
+						td.PlacementProgress = "❌ Cancelled"
+						shared.SaveTorrentDownload(td)
+						placementResults <- td
+						continue
+					}
+
+					// Download, falls back to other releases if it stalls
+					err := DownloadWithFallback(ctx, td, cfg, sessionTitle)
+
+					if errors.Is(err, ErrNothingMissing) {
+						td.Done = true
+						td.MarkPlaced("✅ Already have every episode")
+						placementResults <- td
+						continue
+					}
 
 					if err != nil {
-						if err == context.DeadlineExceeded {
-							logger.Log(true, "Download timeout for %s (no progress in 30 min)", td.Title)
-							td.PlacementProgress = "❌ Timeout - no seeders?"
+						if errors.Is(err, ErrStalled) {
+							logger.Log(true, "Download stalled for %s: %v", td.Title, err)
+							td.PlacementProgress = "❌ Stalled - no seeders?"
+						} else if errors.Is(err, ErrMetadataTimeout) {
+							logger.Log(true, "Download failed for %s: %v", td.Title, err)
+							td.PlacementProgress = "❌ Timed out - indexer or tracker slow?"
 						} else if err == context.Canceled {
 							td.PlacementProgress = "❌ Cancelled"
 						} else {
@@ -97,18 +117,13 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string) {
 							td.PlacementProgress = "❌ Failed"
 						}
 						shared.SaveTorrentDownload(td)
+						removeSeedJob(td.TorrentID)
 						placementResults <- td
 						continue
 					}
 
 					// Place immediately after download completes
-					tmpDir := filepath.Join(os.TempDir(), fmt.Sprintf("opfor-tmp-%d", td.TorrentID))
-					matcher.ProcessTorrentFiles(tmpDir, outDir, td, metadataIndex)
-
-					// Clean up temp directory immediately
-					if err := os.RemoveAll(tmpDir); err != nil {
-						logger.Log(false, "Failed to remove temp dir %s: %v", tmpDir, err)
-					}
+					PlaceDownload(td, cfg, metadataIndex)
 
 					placementResults <- td
 				}
@@ -152,7 +167,101 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string) {
 
 	if ctx.Err() != nil {
 		logger.Log(true, "\n❌ Downloads cancelled by user.")
-	} else {
-		logger.Log(true, "\n✅ All downloads finished and placed.")
+		return
+	}
+
+	logger.Log(true, "\n✅ All downloads finished and placed.")
+
+	if cfg.Seeding.Enabled {
+		seedSession(ctx, cfg)
+	}
+}
+
+// keeps td pending until the schedule allows downloads
+func waitForWindow(ctx context.Context, td *shared.TorrentDownload, schedule shared.ScheduleConfig) error {
+	for !schedule.Open(time.Now()) {
+		next := schedule.NextOpen(time.Now())
+		wait := time.Until(next)
+		if next.IsZero() {
+			td.MarkPending("No valid download window")
+			wait = time.Minute
+		} else {
+			td.MarkPending("Waiting until " + next.Format("15:04"))
+		}
+
+		// wake up at least once a minute, the clock may jump
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(wait, time.Minute)):
+		}
+	}
+
+	td.ClearPending()
+	return nil
+}
+
+// seeds until every job reached its target or the user stops it. unfinished jobs are picked up by the next session or 'serve'
+func seedSession(ctx context.Context, cfg shared.Config) {
+	if len(LoadSeedJobs()) == 0 {
+		return
+	}
+
+	seeder := NewSeeder(cfg)
+	if err := seeder.Start(); err != nil {
+		logger.Log(true, "⚠️  %v", err)
+		return
+	}
+	defer seeder.Stop()
+
+	logger.Log(true, "🌱 Seeding %s, press Ctrl+C to stop. Progress is kept for the next session or 'serve'.", seedTargetDescription(cfg.Seeding))
+	seeder.Wait(ctx)
+}
+
+// e.g "until ratio 1.00 or 60 min"
+func seedTargetDescription(c shared.SeedingConfig) string {
+	ratio := c.Ratio
+	if ratio <= 0 && c.Minutes <= 0 {
+		ratio = shared.DefaultSeedRatio
+	}
+
+	switch {
+	case ratio > 0 && c.Minutes > 0:
+		return fmt.Sprintf("until ratio %.2f or %d min", ratio, c.Minutes)
+	case c.Minutes > 0:
+		return fmt.Sprintf("for %d min", c.Minutes)
+	default:
+		return fmt.Sprintf("until ratio %.2f", ratio)
+	}
+}
+
+// display title of a download in the session progress list
+func sessionTitle(entry shared.TorrentEntry) string {
+	dKey := ui.StyleFactory(fmt.Sprintf("%4d", entry.DownloadKey), ui.Style.Pink)
+	title := ui.StyleFactory(entry.TorrentName, ui.Style.LBlue)
+	return fmt.Sprintf("%s: %s (%s)", dKey, title, entry.Quality)
+}
+
+// places a finished internal download, queues it for seeding and removes it from the incomplete dir
+func PlaceDownload(td *shared.TorrentDownload, cfg shared.Config, index *shared.MetadataIndex) {
+	matcher.ProcessTorrentFiles(td.SavePath, sessionLibraries(cfg, td.Quality), td, index)
+	if cfg.Seeding.Enabled {
+		QueueSeed(td)
+	}
+
+	if err := os.RemoveAll(td.SavePath); err != nil {
+		logger.Log(false, "Failed to remove download dir %s: %v", td.SavePath, err)
+	}
+}
+
+// libraries for an internal download. the download dir is removed after placement, so symlinks would dangle and are hardlinked instead
+func sessionLibraries(cfg shared.Config, quality string) []shared.LibraryConfig {
+	libs := cfg.LibrariesFor(quality)
+	for i := range libs {
+		if libs[i].Placement == shared.PlacementSymlink {
+			logger.Log(false, "Library %s uses symlinks, hardlinking instead for internal downloads", libs[i].Name)
+			libs[i].Placement = shared.PlacementHardlink
+		}
 	}
+	return libs
 }
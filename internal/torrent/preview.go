@@ -0,0 +1,120 @@
+// torrent/preview.go
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"opforjellyfin/internal/matcher"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/shared"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Preview lists what a torrent contains and where each file would be placed
+type Preview struct {
+	Title        string        `json:"title"`
+	ChapterRange string        `json:"chapterRange"`
+	Quality      string        `json:"quality"`
+	TotalSize    int64         `json:"totalSize"`
+	Files        []PreviewFile `json:"files"`
+}
+
+type PreviewFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Video   bool   `json:"video"`
+	Episode string `json:"episode,omitempty"` // episode title the file is placed as
+	Target  string `json:"target,omitempty"`  // path relative to the primary library
+	Matched bool   `json:"matched"`
+	Have    bool   `json:"have"` // target already exists
+}
+
+// PreviewTorrent fetches the .torrent or magnet metadata for entry and maps its files with the matcher, without downloading anything
+func PreviewTorrent(ctx context.Context, entry shared.TorrentEntry, cfg shared.Config) (*Preview, error) {
+	info, err := fetchInfo(ctx, cfg, entry.Source(cfg.Source.BaseURL))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch torrent: %w", err)
+	}
+
+	index := metadata.LoadMetadataCache()
+
+	preview := &Preview{
+		Title:        entry.Title,
+		ChapterRange: entry.ChapterRange,
+		Quality:      entry.Quality,
+		TotalSize:    info.TotalLength(),
+	}
+
+	for _, fi := range info.UpvertedFiles() {
+		filePath := strings.Join(append([]string{info.BestName()}, fi.BestPath()...), "/")
+
+		pf := PreviewFile{
+			Path:  filePath,
+			Size:  fi.Length,
+			Video: matcher.IsVideoFile(filePath),
+		}
+
+		if pf.Video {
+			if target := matcher.ResolveTarget(path.Base(filePath), index, entry.ChapterRange, cfg.TargetDir); target != "" {
+				pf.Matched = true
+				pf.Episode = filepath.Base(target)
+				pf.Target, _ = filepath.Rel(cfg.TargetDir, target+path.Ext(filePath))
+				pf.Have = shared.FileExists(target+".mkv") || shared.FileExists(target+".mp4")
+			}
+		}
+
+		preview.Files = append(preview.Files, pf)
+	}
+
+	return preview, nil
+}
+
+// file list of a .torrent URL or magnet. magnets are resolved through the shared client, without downloading data
+func fetchInfo(ctx context.Context, cfg shared.Config, source string) (*metainfo.Info, error) {
+	if !shared.IsMagnet(source) {
+		meta, err := fetchMetaInfo(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		info, err := meta.UnmarshalInfo()
+		if err != nil {
+			return nil, fmt.Errorf("invalid torrent: %w", err)
+		}
+		return &info, nil
+	}
+
+	spec, err := torrent.TorrentSpecFromMagnetUri(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnet: %w", err)
+	}
+
+	client, err := AcquireClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start torrent client: %w", err)
+	}
+	defer ReleaseClient()
+
+	t, isNew, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	// a running download of the same torrent must not be dropped
+	if isNew {
+		defer t.Drop()
+	}
+
+	select {
+	case <-t.GotInfo():
+		return t.Info(), nil
+	case <-time.After(magnetInfoTimeout):
+		return nil, fmt.Errorf("timeout waiting for magnet metadata")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,257 @@
+// torrent/seeder.go
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+const seedPollInterval = 15 * time.Second
+
+var errStillDownloading = errors.New("torrent is still downloading")
+
+// a completed download waiting to reach its seeding target, persisted in the config dir
+type SeedJob struct {
+	TorrentID     int               `json:"torrent_id"`
+	Title         string            `json:"title"`
+	Files         map[string]string `json:"files"` // torrent file path -> placed library file
+	Size          int64             `json:"size"`
+	Uploaded      int64             `json:"uploaded"`
+	SeededSeconds int64             `json:"seeded_seconds"`
+	Added         time.Time         `json:"added"`
+}
+
+// Seeder seeds completed internal downloads from their placed files until the configured target is reached
+type Seeder struct {
+	cfg      shared.SeedingConfig
+	appCfg   shared.Config
+	client   *torrent.Client
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[int]*seeding
+}
+
+// a job loaded into the client
+type seeding struct {
+	job      *SeedJob
+	t        *torrent.Torrent
+	storage  storage.ClientImplCloser
+	since    time.Time // last time seed time was counted
+	uploaded int64     // client upload counter at the last update
+}
+
+func NewSeeder(cfg shared.Config) *Seeder {
+	return &Seeder{
+		cfg:      cfg.Seeding,
+		appCfg:   cfg,
+		stopChan: make(chan struct{}),
+		active:   make(map[int]*seeding),
+	}
+}
+
+// starts the client and picks up every saved seed job
+func (s *Seeder) Start() error {
+	if err := shared.CreateDirectory(seedDir()); err != nil {
+		return fmt.Errorf("could not create seed dir: %w", err)
+	}
+
+	client, err := AcquireClient(s.appCfg)
+	if err != nil {
+		return fmt.Errorf("could not start seeding client: %w", err)
+	}
+	s.client = client
+
+	logger.Log(false, "Starting seeder (polling every %v)", seedPollInterval)
+	s.refresh()
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+// saves progress and closes the client. jobs that did not reach their target continue on the next start
+func (s *Seeder) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		s.wg.Wait()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for id, sd := range s.active {
+			s.updateJob(sd)
+			saveSeedJob(sd.job)
+			sd.t.Drop()
+			sd.storage.Close()
+			delete(s.active, id)
+		}
+
+		ReleaseClient()
+		logger.Log(false, "Seeder stopped")
+	})
+}
+
+// blocks until every job reached its target or ctx is cancelled
+func (s *Seeder) Wait(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			n := len(s.active)
+			s.mu.Unlock()
+			if n == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (s *Seeder) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(seedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// adds new jobs from disk, saves progress and drops jobs that reached their target
+func (s *Seeder) refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range LoadSeedJobs() {
+		if _, exists := s.active[job.TorrentID]; exists {
+			continue
+		}
+		if err := s.add(job); errors.Is(err, errStillDownloading) {
+			continue
+		} else if err != nil {
+			logger.Log(true, "🌱 Could not seed %s: %v", job.Title, err)
+			removeSeedJob(job.TorrentID)
+		}
+	}
+
+	for id, sd := range s.active {
+		s.updateJob(sd)
+
+		if !s.cfg.Reached(sd.job.Uploaded, sd.job.Size, time.Duration(sd.job.SeededSeconds)*time.Second) {
+			saveSeedJob(sd.job)
+			continue
+		}
+
+		logger.Log(true, "🌱 Finished seeding %s (uploaded %.2f MB)", sd.job.Title, float64(sd.job.Uploaded)/(1024*1024))
+		sd.t.Drop()
+		sd.storage.Close()
+		removeSeedJob(id)
+		delete(s.active, id)
+	}
+}
+
+func (s *Seeder) add(job *SeedJob) error {
+	meta, err := metainfo.LoadFromFile(seedTorrentPath(job.TorrentID))
+	if err != nil {
+		return fmt.Errorf("could not load torrent: %w", err)
+	}
+
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		return fmt.Errorf("invalid torrent: %w", err)
+	}
+
+	store := placedFileStorage(job)
+	t, isNew := s.client.AddTorrentOpt(torrent.AddTorrentOpts{
+		InfoHash:  meta.HashInfoBytes(),
+		InfoBytes: meta.InfoBytes,
+		Storage:   store,
+	})
+	if !isNew {
+		// the download of the same torrent is still in the shared client, try again on the next refresh
+		store.Close()
+		return errStillDownloading
+	}
+	t.AddTrackers(meta.UpvertedAnnounceList())
+
+	// never write to the library, only serve pieces that verify against the placed files
+	t.DisallowDataDownload()
+	go t.VerifyData()
+
+	if job.Size == 0 {
+		job.Size = info.TotalLength()
+	}
+
+	s.active[job.TorrentID] = &seeding{
+		job:     job,
+		t:       t,
+		storage: store,
+		since:   time.Now(),
+	}
+
+	logger.Log(false, "Seeding %s from %d placed file(s)", job.Title, len(job.Files))
+	return nil
+}
+
+// adds upload and seed time since the last update to the job
+func (s *Seeder) updateJob(sd *seeding) {
+	stats := sd.t.Stats()
+	uploaded := stats.BytesWrittenData.Int64()
+	sd.job.Uploaded += uploaded - sd.uploaded
+	sd.uploaded = uploaded
+
+	elapsed := time.Since(sd.since).Truncate(time.Second)
+	sd.job.SeededSeconds += int64(elapsed.Seconds())
+	sd.since = sd.since.Add(elapsed)
+}
+
+// file storage that reads each torrent file from where it was placed. files that were not placed map into the seed dir and stay missing.
+func placedFileStorage(job *SeedJob) storage.ClientImplCloser {
+	scratch := filepath.Join(seedDir(), fmt.Sprintf("%d", job.TorrentID))
+
+	// the storage joins every file onto the torrent dir, so use the volume root and hand out rooted paths
+	root := filepath.VolumeName(scratch) + string(filepath.Separator)
+
+	return storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir:   scratch,
+		TorrentDirMaker: func(string, *metainfo.Info, metainfo.Hash) string { return root },
+		FilePathMaker: func(opts storage.FilePathMakerOpts) string {
+			// same layout the download used below its temp dir
+			rel := strings.Join(append([]string{opts.Info.BestName()}, opts.File.BestPath()...), "/")
+
+			path, ok := job.Files[rel]
+			if !ok || filepath.VolumeName(path) != filepath.VolumeName(scratch) {
+				path = filepath.Join(scratch, filepath.FromSlash(rel))
+			}
+			return strings.TrimPrefix(path, filepath.VolumeName(path))
+		},
+		// placed files are hashed again on every start, nothing to persist
+		PieceCompletion: storage.NewMapPieceCompletion(),
+	})
+}
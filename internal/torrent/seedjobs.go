@@ -0,0 +1,129 @@
+// torrent/seedjobs.go
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// seed jobs and their .torrent files live here, so serve can pick up what a CLI session started
+func seedDir() string {
+	return filepath.Join(shared.ConfigDir(), "seeding")
+}
+
+func seedTorrentPath(torrentID int) string {
+	return filepath.Join(seedDir(), fmt.Sprintf("%d.torrent", torrentID))
+}
+
+func seedJobPath(torrentID int) string {
+	return filepath.Join(seedDir(), fmt.Sprintf("%d.json", torrentID))
+}
+
+// keeps the .torrent of a download around until we know if it can be seeded
+func saveSeedTorrent(torrentID int, meta *metainfo.MetaInfo) error {
+	if err := shared.CreateDirectory(seedDir()); err != nil {
+		return err
+	}
+
+	f, err := os.Create(seedTorrentPath(torrentID))
+	if err != nil {
+		return err
+	}
+	if err := meta.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// QueueSeed saves a seed job for a placed download. downloads without placed files or a saved .torrent are skipped.
+func QueueSeed(td *shared.TorrentDownload) bool {
+	if !shared.FileExists(seedTorrentPath(td.TorrentID)) {
+		return false
+	}
+
+	if len(td.PlacedFiles) == 0 {
+		logger.Log(false, "Not seeding %s: nothing was placed", td.Title)
+		removeSeedJob(td.TorrentID)
+		return false
+	}
+
+	job := &SeedJob{
+		TorrentID: td.TorrentID,
+		Title:     td.FullTitle,
+		Files:     td.PlacedFiles,
+		Size:      td.TotalSize,
+		Added:     time.Now(),
+	}
+
+	if err := saveSeedJob(job); err != nil {
+		logger.Log(true, "⚠️  Could not save seed job for %s: %v", td.Title, err)
+		return false
+	}
+
+	return true
+}
+
+// LoadSeedJobs returns every saved seed job, oldest first
+func LoadSeedJobs() []*SeedJob {
+	entries, err := os.ReadDir(seedDir())
+	if err != nil {
+		return nil
+	}
+
+	var jobs []*SeedJob
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(seedDir(), e.Name()))
+		if err != nil {
+			logger.Log(false, "seeding: could not read %s: %v", e.Name(), err)
+			continue
+		}
+
+		job := &SeedJob{}
+		if err := json.Unmarshal(data, job); err != nil {
+			logger.Log(false, "seeding: invalid job %s: %v", e.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Added.Before(jobs[j].Added)
+	})
+
+	return jobs
+}
+
+func saveSeedJob(job *SeedJob) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(seedJobPath(job.TorrentID), data, 0644)
+}
+
+// removes the job, its .torrent and the scratch dir for files that were never placed
+func removeSeedJob(torrentID int) {
+	for _, path := range []string{
+		seedJobPath(torrentID),
+		seedTorrentPath(torrentID),
+		filepath.Join(seedDir(), fmt.Sprintf("%d", torrentID)),
+	} {
+		if err := os.RemoveAll(path); err != nil {
+			logger.Log(false, "seeding: could not remove %s: %v", path, err)
+		}
+	}
+}
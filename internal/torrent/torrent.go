@@ -3,9 +3,12 @@ package torrent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/matcher"
+	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/shared"
 	"time"
 
@@ -13,93 +16,174 @@ import (
 	"github.com/anacrolix/torrent/metainfo"
 )
 
+// returned when a download made no progress or had no peers for too long
+var ErrStalled = errors.New("download stalled")
+
+// returned when the file list didn't arrive in time though peers may be there, e.g a slow tracker.
+// not a reason to blocklist the release
+var ErrMetadataTimeout = errors.New("timeout waiting for torrent metadata")
+
+// magnets have no file list until a peer sent it
+const magnetInfoTimeout = 3 * time.Minute
+
+// returned when every episode in a torrent is already in the libraries
+var ErrNothingMissing = errors.New("all episodes already present")
+
 // main torrent download and tracker
 func StartTorrent(ctx context.Context, td *shared.TorrentDownload) error {
-	// get torrent meta-info
-	torrentURL := fmt.Sprintf("%s/download/%d.torrent", shared.LoadConfig().Source.BaseURL, td.TorrentID)
-	logger.Log(false, "Fetching torrent: %s, ID: %s", torrentURL, td)
+	appCfg := shared.LoadConfig()
 
-	// get metadata
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, torrentURL, nil)
-	resp, err := http.DefaultClient.Do(req)
+	// all downloads share one client, partial data stays in the incomplete dir
+	client, err := AcquireClient(appCfg)
 	if err != nil {
-		logger.Log(false, "HTTP request for metadata failed %s", td)
-		return err
+		return fmt.Errorf("failed to start torrent client: %w", err)
 	}
-	defer resp.Body.Close()
+	defer ReleaseClient()
 
-	//build meta
-	meta, err := metainfo.Load(resp.Body)
+	// add torrent, pieces already on disk are picked up from the piece completion
+	source := td.Source(appCfg.Source.BaseURL)
+	t, err := addTorrent(ctx, client, source)
 	if err != nil {
+		logger.Log(false, "Could not add torrent %s: %v", td.Title, err)
 		return err
 	}
+	// drop keeps the files, so a cancelled download resumes next time
+	defer t.Drop()
 
-	// create tempdir using safe function
-	tmpDir, err := shared.CreateTempTorrentDir(td.TorrentID)
-	if err != nil {
-		return fmt.Errorf("failed to create temp dir: %w", err)
-	}
-
-	// start the torrent-client with optimized settings for concurrent downloads
-	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = tmpDir
-	cfg.NoUpload = true
-	cfg.ListenPort = 0
+	td.SavePath = downloadDir(t)
+	td.InfoHash = t.InfoHash().HexString()
 
-	client, err := torrent.NewClient(cfg)
-	if err != nil {
-		return err
-	}
-	defer closeWithLogs(client)
-
-	// add torrent
-	t, err := client.AddTorrent(meta)
-	if err != nil {
-		return err
+	// magnets need peers before the file list is known
+	infoTimeout := 20 * time.Second
+	if shared.IsMagnet(source) {
+		infoTimeout = magnetInfoTimeout
 	}
 
 	// get torrent metadata
 	select {
 	case <-t.GotInfo():
 		td.TotalSize = t.Length()
-		logger.Log(false, "Torrent metadata loaded: %s", td)
-	case <-time.After(20 * time.Second):
-		return fmt.Errorf("timeout waiting for torrent metadata")
+		logger.Log(false, "Torrent metadata loaded: %s", td.Title)
+	case <-time.After(infoTimeout):
+		// a magnet nobody answers for is dead, the same as a stall
+		if shared.IsMagnet(source) && t.Stats().ActivePeers == 0 {
+			return fmt.Errorf("%w: no peers sent the file list", ErrStalled)
+		}
+		return ErrMetadataTimeout
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 
-	// start download
-	td.TotalSize = t.Length()
-	t.DownloadAll()
+	// keep the .torrent, the seeder needs it once files are placed
+	if appCfg.Seeding.Enabled {
+		meta := t.Metainfo()
+		if err := saveSeedTorrent(td.TorrentID, &meta); err != nil {
+			logger.Log(false, "Could not save torrent for seeding %s: %v", td.Title, err)
+		}
+	}
+
+	// start download, only the files for episodes we don't have yet
+	files := selectFiles(t, td, appCfg)
+	if len(files) == 0 {
+		removeSeedJob(td.TorrentID)
+		return ErrNothingMissing
+	}
+
+	td.TotalSize = 0
+	for _, f := range files {
+		f.Download()
+		td.TotalSize += f.Length()
+	}
 	shared.SaveTorrentDownload(td)
 
 	// watch progress, save to activefile
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	for t.BytesMissing() > 0 {
+	stall := shared.NewStallDetector(appCfg.Retry, time.Now())
+	paused := false
+
+	for bytesCompleted(files) < td.TotalSize {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
-			td.Progress = t.BytesCompleted()
+		case now := <-ticker.C:
+			td.Progress = bytesCompleted(files)
+
+			// running downloads stop outside the download windows too, peers stay connected
+			if open := appCfg.Schedule.Open(now); open == paused {
+				paused = !open
+				if paused {
+					t.DisallowDataDownload()
+					td.PlacementProgress = "⏸️ Paused outside the download window"
+					logger.Log(false, "Paused %s outside the download window", td.Title)
+				} else {
+					t.AllowDataDownload()
+					td.PlacementProgress = ""
+					stall = shared.NewStallDetector(appCfg.Retry, now)
+					logger.Log(false, "Resumed %s", td.Title)
+				}
+			}
 			shared.SaveTorrentDownload(td)
+			if paused {
+				continue
+			}
+
+			stats := t.Stats()
+			stall.Observe(now, td.Progress, stats.ActivePeers)
+			if stall.Stalled(now) {
+				return ErrStalled
+			}
 		}
 	}
 
-	// close
 	td.Progress = td.TotalSize
-	logger.Log(false, "Torrent contains %d files", len(t.Files()))
+	logger.Log(false, "Downloaded %d of %d files", len(files), len(t.Files()))
 
 	td.Done = true
 	td.PlacementProgress = "⏳ Waiting to place.."
 	shared.SaveTorrentDownload(td)
-	logger.Log(false, "Download complete: %s", td)
+	logger.Log(false, "Download complete: %s", td.Title)
 
 	return nil
 }
 
+// adds a .torrent URL or a magnet to the client
+func addTorrent(ctx context.Context, client *torrent.Client, source string) (*torrent.Torrent, error) {
+	if shared.IsMagnet(source) {
+		logger.Log(false, "Adding magnet: %s", source)
+		return client.AddMagnet(source)
+	}
+
+	meta, err := fetchMetaInfo(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return client.AddTorrent(meta)
+}
+
+// downloads and parses a .torrent
+func fetchMetaInfo(ctx context.Context, torrentURL string) (*metainfo.MetaInfo, error) {
+	logger.Log(false, "Fetching torrent: %s", torrentURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, torrentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return metainfo.Load(resp.Body)
+}
+
 // loghelper
 func closeWithLogs(client *torrent.Client) {
 	if client != nil {
@@ -107,3 +191,40 @@ func closeWithLogs(client *torrent.Client) {
 		client.Close()
 	}
 }
+
+// picks the video files to download and records them on td
+func selectFiles(t *torrent.Torrent, td *shared.TorrentDownload, cfg shared.Config) []*torrent.File {
+	all := t.Files()
+	paths := make([]string, len(all))
+	for i, f := range all {
+		paths[i] = f.Path()
+	}
+
+	wanted := matcher.SelectMissingFiles(paths, sessionLibraries(cfg, td.Quality), metadata.LoadMetadataCache(), td.ChapterRange)
+
+	var files []*torrent.File
+	videos := 0
+	td.SelectedFiles = []string{}
+	for i, f := range all {
+		if matcher.IsVideoFile(paths[i]) {
+			videos++
+		}
+		if wanted[i] {
+			files = append(files, f)
+			td.SelectedFiles = append(td.SelectedFiles, paths[i])
+		}
+	}
+
+	if len(files) < videos {
+		logger.Log(true, "📦 %s: downloading %d of %d videos, the rest is already in your library", td.Title, len(files), videos)
+	}
+
+	return files
+}
+
+func bytesCompleted(files []*torrent.File) (n int64) {
+	for _, f := range files {
+		n += f.BytesCompleted()
+	}
+	return n
+}
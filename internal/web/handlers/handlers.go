@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"math"
 	"net/http"
+	"net/url"
 	"opforjellyfin/internal/downloader"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
 	"os"
 	"path/filepath"
 	"sort"
@@ -112,6 +116,7 @@ type ArcStatus struct {
 	VideoStatus  int    `json:"videoStatus"`
 	EpisodeCount int    `json:"episodeCount"`
 	DownloadKey  int    `json:"downloadKey"`
+	Poster       string `json:"poster,omitempty"`
 }
 
 type EpisodeStatus struct {
@@ -182,6 +187,7 @@ func APIListArcs(w http.ResponseWriter, r *http.Request) {
 			VideoStatus:  metadata.HaveVideoStatus(season.Range),
 			EpisodeCount: len(season.EpisodeRange),
 			DownloadKey:  downloadKeyMap[season.Range],
+			Poster:       artworkURL(cfg.TargetDir, seasonKey, season.Artwork.Poster),
 		}
 		arcs = append(arcs, arc)
 	}
@@ -251,18 +257,13 @@ func APIGetArcDetails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var episodes []EpisodeStatus
-	seasonDir := filepath.Join(cfg.TargetDir, seasonKey)
-
-	for epRange, epData := range season.EpisodeRange {
-		videoPathMP4 := filepath.Join(seasonDir, epData.Title+".mp4")
-		videoPathMKV := filepath.Join(seasonDir, epData.Title+".mkv")
-		hasVideo := shared.FileExists(videoPathMP4) || shared.FileExists(videoPathMKV)
 
+	for _, epData := range index.Chapters.EpisodesIn(seasonKey, 0, math.MaxInt) {
 		ep := EpisodeStatus{
 			Title:        epData.Title,
-			ChapterRange: epRange,
-			HasVideo:     hasVideo,
-			DownloadKey:  downloadKeyMap[epRange],
+			ChapterRange: epData.ChapterRange,
+			HasVideo:     metadata.HaveEpisodeVideo(cfg.TargetDir, epData),
+			DownloadKey:  downloadKeyMap[epData.ChapterRange],
 		}
 		episodes = append(episodes, ep)
 	}
@@ -288,6 +289,7 @@ func APIGetArcDetails(w http.ResponseWriter, r *http.Request) {
 			VideoStatus:  metadata.HaveVideoStatus(season.Range),
 			EpisodeCount: len(season.EpisodeRange),
 			DownloadKey:  downloadKeyMap[season.Range],
+			Poster:       artworkURL(cfg.TargetDir, seasonKey, season.Artwork.Poster),
 		},
 		"episodes": episodes,
 	}
@@ -301,6 +303,128 @@ func APIGetArcDetails(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// artwork endpoint url for a season, empty if there is no artwork.
+// v is the file's modification time, so replaced artwork gets a new url
+func artworkURL(baseDir, seasonKey, path string) string {
+	if path == "" {
+		return ""
+	}
+
+	u := "/api/arcs/artwork?type=poster&seasonKey=" + url.QueryEscape(seasonKey)
+	if info, err := os.Stat(filepath.Join(baseDir, path)); err == nil {
+		u += "&v=" + strconv.FormatInt(info.ModTime().UnixMilli(), 10)
+	}
+	return u
+}
+
+// largest artwork upload, posters and fanart are far smaller
+const maxArtworkSize = 10 << 20
+
+// APIArcArtwork serves show or season artwork (GET), or stores user supplied replacement artwork (POST).
+// seasonKey selects the season, leave it empty for the show. type is poster, fanart or banner.
+func APIArcArtwork(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxArtworkSize)
+		if err := r.ParseMultipartForm(maxArtworkSize); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Artwork is larger than %d MB", maxArtworkSize>>20), http.StatusRequestEntityTooLarge)
+			} else {
+				http.Error(w, "Invalid upload", http.StatusBadRequest)
+			}
+			return
+		}
+	}
+
+	seasonKey := r.FormValue("seasonKey")
+	kind := r.FormValue("type")
+	if kind == "" {
+		kind = "poster"
+	}
+
+	if !metadata.IsArtworkKind(kind) {
+		http.Error(w, "type must be poster, fanart or banner", http.StatusBadRequest)
+		return
+	}
+
+	cfg := shared.LoadConfig()
+	if cfg.TargetDir == "" {
+		http.Error(w, "Please set target directory first", http.StatusBadRequest)
+		return
+	}
+
+	index := metadata.LoadMetadataCache()
+	if _, exists := index.Seasons[seasonKey]; seasonKey != "" && !exists {
+		http.Error(w, "Arc not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		path, ok := metadata.ArtworkPath(cfg.TargetDir, index, seasonKey, kind)
+		if !ok {
+			http.Error(w, "No artwork found", http.StatusNotFound)
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "No artwork found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// a versioned url never changes, without one the browser revalidates through Last-Modified
+		if r.FormValue("v") != "" {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+
+	case http.MethodPost:
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "file parameter required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		ext := filepath.Ext(header.Filename)
+		if !metadata.IsArtworkExt(ext) {
+			http.Error(w, "Unsupported image type", http.StatusBadRequest)
+			return
+		}
+
+		if err := metadata.SaveCustomArtwork(cfg, seasonKey, kind, ext, file); err != nil {
+			logger.Log(true, "Failed to save artwork: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to save artwork: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := metadata.BuildMetadataIndex(cfg.TargetDir); err != nil {
+			logger.Log(true, "Failed to rebuild metadata index: %v", err)
+		}
+
+		InvalidateArcsCache()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true,
+			"message": fmt.Sprintf("Saved %s artwork", kind),
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func APISearchArcs(w http.ResponseWriter, r *http.Request) {
 	rangeFilter := r.URL.Query().Get("range")
 	if rangeFilter == "" {
@@ -387,6 +511,91 @@ func matchesChapterRange(torrentRange, searchRange string) bool {
 	return false
 }
 
+// APILookup answers which arc and episode(s) cover a chapter or chapter range, e.g. /api/lookup?chapter=1000
+func APILookup(w http.ResponseWriter, r *http.Request) {
+	chapter := r.URL.Query().Get("chapter")
+	if chapter == "" {
+		http.Error(w, "chapter parameter required", http.StatusBadRequest)
+		return
+	}
+
+	start, end, err := shared.ParseChapterQuery(chapter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg := shared.LoadConfig()
+	if cfg.TargetDir == "" {
+		http.Error(w, "Please set target directory first", http.StatusBadRequest)
+		return
+	}
+
+	results := metadata.LookupChapters(start, end)
+	if results == nil {
+		results = []metadata.ChapterLookup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"chapter": chapter,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// APITorrentPreview lists the files of a torrent and where they would be placed, e.g /api/torrents/preview?downloadKey=12
+func APITorrentPreview(w http.ResponseWriter, r *http.Request) {
+	downloadKey, err := strconv.Atoi(r.URL.Query().Get("downloadKey"))
+	if err != nil {
+		http.Error(w, "downloadKey parameter required", http.StatusBadRequest)
+		return
+	}
+
+	cfg := shared.LoadConfig()
+	if cfg.TargetDir == "" {
+		http.Error(w, "Please set target directory first", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	torrents, err := scraper.FetchTorrents(cfg)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"message": "Failed to fetch torrents",
+		})
+		return
+	}
+
+	entry := scraper.FindByDownloadKey(torrents, downloadKey)
+	if entry == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"message": "Torrent not found",
+		})
+		return
+	}
+
+	preview, err := torrent.PreviewTorrent(r.Context(), *entry, cfg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"preview": preview,
+	})
+}
+
 func APISearchAndDownloadAll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -448,9 +657,9 @@ func APISearchAndDownloadAll(w http.ResponseWriter, r *http.Request) {
 	if fullSeasonTorrent != nil {
 		// Found full season, download it
 		logger.Log(true, "Found full season torrent for %s: %s", rangeFilter, fullSeasonTorrent.TorrentName)
-		torrentURL := fmt.Sprintf("%s/download/%d.torrent", cfg.Source.BaseURL, fullSeasonTorrent.TorrentID)
+		torrentURL := fullSeasonTorrent.Source(cfg.Source.BaseURL)
 
-		if err := downloader.QueueDownload(fullSeasonTorrent, torrentURL, cfg); err != nil {
+		if err := downloader.QueueDownload(r.Context(), fullSeasonTorrent, torrentURL, cfg); err != nil {
 			logger.Log(true, "Failed to queue full season: %v", err)
 		} else {
 			queuedCount++
@@ -470,16 +679,15 @@ func APISearchAndDownloadAll(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Find the season
-		var season *shared.SeasonIndex
-		for key, s := range index.Seasons {
-			if key == seasonKey || s.Range == rangeFilter {
-				season = &s
-				break
+		// Find the season, by key first and by range second
+		if _, ok := index.Seasons[seasonKey]; !ok {
+			seasonKey = ""
+			if s, found := index.Chapters.SeasonExact(rangeFilter); found {
+				seasonKey = s.SeasonKey
 			}
 		}
 
-		if season == nil {
+		if seasonKey == "" {
 			w.WriteHeader(http.StatusNotFound)
 			json.NewEncoder(w).Encode(map[string]any{
 				"success": false,
@@ -498,10 +706,11 @@ func APISearchAndDownloadAll(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Queue each episode
-		for epRange := range season.EpisodeRange {
+		for _, ep := range index.Chapters.EpisodesIn(seasonKey, 0, math.MaxInt) {
+			epRange := ep.ChapterRange
 			if torrent, ok := torrentMap[epRange]; ok {
-				torrentURL := fmt.Sprintf("%s/download/%d.torrent", cfg.Source.BaseURL, torrent.TorrentID)
-				if err := downloader.QueueDownload(torrent, torrentURL, cfg); err != nil {
+				torrentURL := torrent.Source(cfg.Source.BaseURL)
+				if err := downloader.QueueDownload(r.Context(), torrent, torrentURL, cfg); err != nil {
 					logger.Log(true, "Failed to queue episode %s: %v", epRange, err)
 				} else {
 					queuedCount++
@@ -592,10 +801,14 @@ func APIDownloadArc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	torrentURL := fmt.Sprintf("%s/download/%d.torrent", cfg.Source.BaseURL, match.TorrentID)
+	torrentURL := match.Source(cfg.Source.BaseURL)
 
-	if err := downloader.QueueDownload(match, torrentURL, cfg); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := downloader.QueueDownload(r.Context(), match, torrentURL, cfg); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, downloader.ErrNoSpace) {
+			status = http.StatusInsufficientStorage
+		}
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(map[string]any{
 			"success": false,
 			"message": fmt.Sprintf("Failed to queue download: %v", err),
@@ -626,11 +839,20 @@ func APIUpdateSettings(w http.ResponseWriter, r *http.Request) {
 	cfg := shared.LoadConfig()
 
 	if targetDir := r.FormValue("targetDir"); targetDir != "" {
-		cfg.TargetDir = targetDir
+		cfg.SetTargetDir(targetDir)
 	}
 
 	if clientType := r.FormValue("clientType"); clientType != "" {
 		cfg.TorrentClient.Type = clientType
+		// unchecked boxes are not sent, only the client form has a type
+		cfg.TorrentClient.UploadTorrents = r.FormValue("uploadTorrents") != ""
+		cfg.TorrentClient.AddPaused = r.FormValue("addPaused") != ""
+		cfg.TorrentClient.Sequential = r.FormValue("sequential") != ""
+		// empty falls back to the default category and the client's download dir
+		cfg.TorrentClient.Category = strings.TrimSpace(r.FormValue("clientCategory"))
+		cfg.TorrentClient.SavePath = strings.TrimSpace(r.FormValue("clientSavePath"))
+		cfg.TorrentClient.WatchDir = strings.TrimSpace(r.FormValue("watchDir"))
+		cfg.TorrentClient.CompletedDir = strings.TrimSpace(r.FormValue("completedDir"))
 	}
 
 	if clientURL := r.FormValue("clientUrl"); clientURL != "" {
@@ -684,6 +906,73 @@ func APITestClient(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// APIPathMappings adds (POST remote, local) or removes (DELETE ?remote=) a remote path mapping
+func APIPathMappings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	cfg := shared.LoadConfig()
+
+	switch r.Method {
+	case http.MethodPost:
+		m := shared.PathMapping{
+			Remote: strings.TrimSpace(r.FormValue("remote")),
+			Local:  strings.TrimSpace(r.FormValue("local")),
+		}
+		if err := m.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		cfg.SetPathMapping(m)
+
+	case http.MethodDelete:
+		if !cfg.RemovePathMapping(r.URL.Query().Get("remote")) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{
+				"success": false,
+				"message": "Path mapping not found",
+			})
+			return
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shared.SaveConfig(cfg)
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"message": "Path mappings saved",
+	})
+}
+
+// APICheckPathMappings reports whether the local side of every mapping exists and is readable
+func APICheckPathMappings(w http.ResponseWriter, r *http.Request) {
+	cfg := shared.LoadConfig()
+
+	allOK := true
+	results := []map[string]any{}
+	for _, m := range cfg.TorrentClient.PathMappings {
+		result := map[string]any{"remote": m.Remote, "local": m.Local, "ok": true}
+		if err := m.Validate(); err != nil {
+			result["ok"] = false
+			result["error"] = err.Error()
+			allOK = false
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  allOK,
+		"mappings": results,
+	})
+}
+
 func APISync(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -711,20 +1000,35 @@ func APISync(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// APIDiskSpace reports free space on every client and library disk, and the reserve downloads leave
+func APIDiskSpace(w http.ResponseWriter, r *http.Request) {
+	cfg := shared.LoadConfig()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"disks":   downloader.DiskSpaces(cfg),
+		"reserve": cfg.Disk.Reserve(),
+	})
+}
+
 func APIActivityStatus(w http.ResponseWriter, r *http.Request) {
 	downloads := shared.GetActiveDownloads()
 	cfg := shared.LoadConfig()
 
+	// shared with the worker and other tabs, the client is asked at most every few seconds
+	statuses, _ := downloader.GetDownloadStatuses(downloads, cfg, downloader.StatusMaxAge)
+
 	hasPlacedFiles := false
 	for _, dl := range downloads {
-		if dl.UseExternal {
-			status, err := downloader.GetDownloadStatus(dl, cfg)
-			if err == nil && status != nil {
+		if dl.UseExternal && !dl.Pending {
+			if status, ok := statuses[strings.ToLower(dl.ExternalHash)]; ok {
 				dl.Progress = status.Downloaded
 				dl.TotalSize = status.TotalSize
 				dl.Done = status.IsComplete
 				if status.IsComplete && !dl.Placed {
 					dl.PlacementProgress = "✅ Complete - Ready to organize"
+				} else if status.Paused {
+					dl.PlacementProgress = fmt.Sprintf("⏸️ Paused at %.1f%%", status.Progress)
 				} else if !dl.Done {
 					dl.PlacementProgress = fmt.Sprintf("⏳ Downloading... %.1f%%", status.Progress)
 				}
@@ -9,6 +9,7 @@ import (
 	"opforjellyfin/internal/downloader"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
 	"opforjellyfin/internal/web/handlers"
 )
 
@@ -34,6 +35,15 @@ func StartServer(port int) error {
 	worker.Start()
 	defer worker.Stop()
 
+	if cfg.Seeding.Enabled {
+		seeder := torrent.NewSeeder(cfg)
+		if err := seeder.Start(); err != nil {
+			logger.Log(true, "⚠️  Seeding disabled: %v", err)
+		} else {
+			defer seeder.Stop()
+		}
+	}
+
 	mux := http.NewServeMux()
 
 	staticSubFS, err := fs.Sub(content, "static")
@@ -54,11 +64,17 @@ func StartServer(port int) error {
 	mux.HandleFunc("/api/arcs/search", handlers.APISearchArcs)
 	mux.HandleFunc("/api/arcs/download", handlers.APIDownloadArc)
 	mux.HandleFunc("/api/arcs/download-all", handlers.APISearchAndDownloadAll)
+	mux.HandleFunc("/api/arcs/artwork", handlers.APIArcArtwork)
 	mux.HandleFunc("/api/settings/update", handlers.APIUpdateSettings)
 	mux.HandleFunc("/api/settings/test-client", handlers.APITestClient)
 	mux.HandleFunc("/api/settings/browse", handlers.APIBrowseDirectories)
+	mux.HandleFunc("/api/settings/path-mappings", handlers.APIPathMappings)
+	mux.HandleFunc("/api/settings/path-mappings/check", handlers.APICheckPathMappings)
 	mux.HandleFunc("/api/system/sync", handlers.APISync)
+	mux.HandleFunc("/api/system/disk", handlers.APIDiskSpace)
 	mux.HandleFunc("/api/activity/status", handlers.APIActivityStatus)
+	mux.HandleFunc("/api/lookup", handlers.APILookup)
+	mux.HandleFunc("/api/torrents/preview", handlers.APITorrentPreview)
 
 	mux.HandleFunc("/", handlers.HandleIndex(templates))
 